@@ -0,0 +1,158 @@
+// Package arc implements the Adaptive Replacement Cache.
+//
+// ARC keeps two LRU lists of cached entries: T1 for entries seen once recently and T2 for entries seen at least twice.
+// It also remembers the keys recently evicted from them in the ghost lists B1 and B2.
+// A miss on a key in B1 means that T1 is too small, and a miss on a key in B2 means that T2 is too small,
+// so ARC moves its target size of T1 accordingly, adapting to recency or frequency heavy workloads.
+//
+// See N. Megiddo and D. S. Modha, "ARC: A Self-Tuning, Low Overhead Replacement Cache", FAST 2003.
+package arc
+
+import (
+	"container/list"
+
+	"github.com/denpeshkov/doodles/cache"
+)
+
+var _ cache.Cache[string, any] = (*ARC[string, any])(nil)
+
+type listID int
+
+const (
+	t1 listID = iota
+	t2
+	b1
+	b2
+)
+
+type entry[K comparable, V any] struct {
+	key  K
+	val  V // zero in the ghost lists
+	list listID
+}
+
+// ARC is an Adaptive Replacement Cache.
+type ARC[K comparable, V any] struct {
+	m        map[K]*list.Element // Element's Value is of type *entry
+	lists    [4]*list.List       // lists of *entry, indexed by listID, from the most to the least recently used
+	p        int                 // target size of T1
+	capacity int
+}
+
+// New creates a new [ARC] instance.
+func New[K comparable, V any](capacity int) *ARC[K, V] {
+	if capacity <= 0 {
+		panic("arc: capacity must be > 0")
+	}
+	c := &ARC[K, V]{
+		m:        make(map[K]*list.Element, 2*capacity),
+		capacity: capacity,
+	}
+	for i := range c.lists {
+		c.lists[i] = list.New()
+	}
+	return c
+}
+
+func (c *ARC[K, V]) Get(key K) (V, bool) {
+	e, ok := c.m[key]
+	if !ok {
+		return *(new(V)), false
+	}
+	ent := e.Value.(*entry[K, V])
+	if ent.list == b1 || ent.list == b2 {
+		return *(new(V)), false
+	}
+	c.move(e, t2)
+	return ent.val, true
+}
+
+func (c *ARC[K, V]) Put(key K, val V) {
+	e, ok := c.m[key]
+	if !ok {
+		c.putNew(key, val)
+		return
+	}
+
+	ent := e.Value.(*entry[K, V])
+	switch ent.list {
+	case t1, t2:
+		ent.val = val
+		c.move(e, t2)
+	case b1:
+		// T1 is too small: grow its target.
+		c.p = min(c.p+max(1, c.len(b2)/c.len(b1)), c.capacity)
+		c.replace(false)
+		ent.val = val
+		c.move(e, t2)
+	case b2:
+		// T2 is too small: shrink the target of T1.
+		c.p = max(c.p-max(1, c.len(b1)/c.len(b2)), 0)
+		c.replace(true)
+		ent.val = val
+		c.move(e, t2)
+	}
+}
+
+// Len returns the number of cached entries, not counting the ghost entries.
+func (c *ARC[K, V]) Len() int { return c.len(t1) + c.len(t2) }
+
+func (c *ARC[K, V]) putNew(key K, val V) {
+	l1 := c.len(t1) + c.len(b1)
+	l2 := c.len(t2) + c.len(b2)
+	switch {
+	case l1 == c.capacity:
+		if c.len(t1) < c.capacity {
+			c.removeLRU(b1)
+			c.replace(false)
+		} else {
+			// B1 is empty, so drop the entry instead of keeping its ghost.
+			c.removeLRU(t1)
+		}
+	case l1+l2 >= c.capacity:
+		if l1+l2 == 2*c.capacity {
+			c.removeLRU(b2)
+		}
+		c.replace(false)
+	}
+	ent := &entry[K, V]{key: key, val: val, list: t1}
+	c.m[key] = c.lists[t1].PushFront(ent)
+}
+
+// replace evicts an entry from T1 or T2 into the corresponding ghost list, depending on the target size of T1.
+// inB2 reports whether the requested key is in B2.
+func (c *ARC[K, V]) replace(inB2 bool) {
+	n1 := c.len(t1)
+	if n1 > 0 && (n1 > c.p || (inB2 && n1 == c.p) || c.len(t2) == 0) {
+		c.demote(t1, b1)
+	} else {
+		c.demote(t2, b2)
+	}
+}
+
+// demote moves the least recently used entry of the list to the ghost list.
+func (c *ARC[K, V]) demote(from, to listID) {
+	e := c.lists[from].Back()
+	ent := e.Value.(*entry[K, V])
+	ent.val = *(new(V)) // do not hold on to the value
+	c.move(e, to)
+}
+
+// move makes the entry the most recently used one of the list.
+func (c *ARC[K, V]) move(e *list.Element, to listID) {
+	ent := e.Value.(*entry[K, V])
+	if ent.list == to {
+		c.lists[to].MoveToFront(e)
+		return
+	}
+	c.lists[ent.list].Remove(e)
+	ent.list = to
+	c.m[ent.key] = c.lists[to].PushFront(ent)
+}
+
+func (c *ARC[K, V]) removeLRU(id listID) {
+	ent := c.lists[id].Remove(c.lists[id].Back()).(*entry[K, V])
+	delete(c.m, ent.key)
+}
+
+func (c *ARC[K, V]) len(id listID) int { return c.lists[id].Len() }
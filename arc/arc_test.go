@@ -0,0 +1,120 @@
+package arc
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func keys(c *ARC[int, int], id listID) []int {
+	var ks []int
+	for e := c.lists[id].Front(); e != nil; e = e.Next() {
+		ks = append(ks, e.Value.(*entry[int, int]).key)
+	}
+	return ks
+}
+
+// access requests the key like the ARC paper does: a miss fetches the key into the cache.
+func access(c *ARC[int, int], k int) bool {
+	if _, ok := c.Get(k); ok {
+		return true
+	}
+	c.Put(k, k)
+	return false
+}
+
+// TestReferenceTrace replays a trace through the algorithm in Fig. 4 of the ARC paper
+// with c = 3, checking the hit/miss sequence and the state after every request,
+// which was derived by hand following the paper.
+func TestReferenceTrace(t *testing.T) {
+	type state struct {
+		t1, t2, b1, b2 []int // from the most to the least recently used
+		p              int
+	}
+	tests := []struct {
+		key  int
+		hit  bool
+		want state
+	}{
+		{1, false, state{t1: []int{1}}},
+		{2, false, state{t1: []int{2, 1}}},
+		{3, false, state{t1: []int{3, 2, 1}}},
+		{1, true, state{t1: []int{3, 2}, t2: []int{1}}},
+		{4, false, state{t1: []int{4, 3}, t2: []int{1}, b1: []int{2}}},                     // Case IV.B: REPLACE from T1
+		{2, false, state{t1: []int{4}, t2: []int{2, 1}, b1: []int{3}, p: 1}},               // Case II
+		{3, false, state{t1: []int{4}, t2: []int{3, 2}, b2: []int{1}, p: 2}},               // Case II, REPLACE from T2
+		{1, false, state{t2: []int{1, 3, 2}, b1: []int{4}, p: 1}},                          // Case III, |T1| = p
+		{5, false, state{t1: []int{5}, t2: []int{1, 3}, b1: []int{4}, b2: []int{2}, p: 1}}, // Case IV.B
+		{2, false, state{t2: []int{2, 1, 3}, b1: []int{5, 4}}},                             // Case III
+		{4, false, state{t2: []int{4, 2, 1}, b1: []int{5}, b2: []int{3}, p: 1}},            // Case II
+		{3, false, state{t2: []int{3, 4, 2}, b1: []int{5}, b2: []int{1}}},                  // Case III
+		{6, false, state{t1: []int{6}, t2: []int{3, 4}, b1: []int{5}, b2: []int{2, 1}}},    // Case IV.B
+		{7, false, state{t1: []int{7}, t2: []int{3, 4}, b1: []int{6, 5}, b2: []int{2}}},    // Case IV.B, |L1| + |L2| = 2c
+		{8, false, state{t1: []int{8}, t2: []int{3, 4}, b1: []int{7, 6}, b2: []int{2}}},    // Case IV.A, |T1| < c
+		{4, true, state{t1: []int{8}, t2: []int{4, 3}, b1: []int{7, 6}, b2: []int{2}}},
+		{8, true, state{t2: []int{8, 4, 3}, b1: []int{7, 6}, b2: []int{2}}},
+		{6, false, state{t2: []int{6, 8, 4}, b1: []int{7}, b2: []int{3, 2}, p: 1}},            // Case II
+		{5, false, state{t1: []int{5}, t2: []int{6, 8}, b1: []int{7}, b2: []int{4, 3}, p: 1}}, // Case IV.B, |L1| + |L2| = 2c
+		{3, false, state{t2: []int{3, 6, 8}, b1: []int{5, 7}, b2: []int{4}}},                  // Case III
+	}
+
+	c := New[int, int](3)
+	for i, tt := range tests {
+		if hit := access(c, tt.key); hit != tt.hit {
+			t.Fatalf("request %d (key %d): hit = %t, want %t", i+1, tt.key, hit, tt.hit)
+		}
+		got := state{keys(c, t1), keys(c, t2), keys(c, b1), keys(c, b2), c.p}
+		if !slices.Equal(got.t1, tt.want.t1) || !slices.Equal(got.t2, tt.want.t2) ||
+			!slices.Equal(got.b1, tt.want.b1) || !slices.Equal(got.b2, tt.want.b2) || got.p != tt.want.p {
+			t.Fatalf("request %d (key %d): state = %+v, want %+v", i+1, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestInvariants(t *testing.T) {
+	const capacity = 50
+	c := New[int, int](capacity)
+	rnd := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rnd, 1.1, 1, 500)
+	for i := range 100_000 {
+		var k int
+		if i/10_000%2 == 0 {
+			k = int(zipf.Uint64()) // frequency heavy phase
+		} else {
+			k = 1000 + i%(2*capacity) // recency heavy phase
+		}
+		if hit := access(c, k); hit {
+			if v, _ := c.Get(k); v != k {
+				t.Fatalf("Get(%d) = %d", k, v)
+			}
+		}
+
+		n1, n2, g1, g2 := c.len(t1), c.len(t2), c.len(b1), c.len(b2)
+		switch {
+		case n1+n2 > capacity:
+			t.Fatalf("|T1| + |T2| = %d > c", n1+n2)
+		case n1+g1 > capacity:
+			t.Fatalf("|T1| + |B1| = %d > c", n1+g1)
+		case n1+n2+g1+g2 > 2*capacity:
+			t.Fatalf("|L1| + |L2| = %d > 2c", n1+n2+g1+g2)
+		case c.p < 0 || c.p > capacity:
+			t.Fatalf("p = %d out of [0, c]", c.p)
+		case len(c.m) != n1+n2+g1+g2:
+			t.Fatalf("map has %d keys, lists have %d", len(c.m), n1+n2+g1+g2)
+		case c.Len() != n1+n2:
+			t.Fatalf("Len() = %d, want %d", c.Len(), n1+n2)
+		}
+	}
+}
+
+func TestGhostIsMiss(t *testing.T) {
+	c := New[int, int](1)
+	c.Put(1, 1)
+	c.Put(2, 2) // 1 becomes a ghost
+	if _, ok := c.Get(1); ok {
+		t.Errorf("Get(1) hit on a ghost entry")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
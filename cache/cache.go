@@ -0,0 +1,13 @@
+// Package cache defines the interface shared by the cache implementations in this module.
+package cache
+
+// Cache is a fixed-capacity key-value cache.
+// Implementations are not required to be safe for concurrent use.
+type Cache[K comparable, V any] interface {
+	// Get returns the value for the key and reports whether it was found.
+	Get(key K) (V, bool)
+	// Put adds or updates the value for the key, possibly evicting other entries.
+	Put(key K, val V)
+	// Len returns the number of entries in the cache.
+	Len() int
+}
@@ -0,0 +1,11 @@
+package cache
+
+// Waiters returns the number of callers waiting for the load of the key.
+func Waiters[K comparable, V any](l *Loading[K, V], key K) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if c, ok := l.calls[key]; ok {
+		return c.waiters
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Loader loads the value for a key missing from the cache.
+type Loader[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+// Loading wraps a [Cache] to load missing values, making sure that
+// only one load per key runs at a time. It is safe for concurrent use.
+type Loading[K comparable, V any] struct {
+	mu    sync.Mutex
+	c     Cache[K, V]
+	calls map[K]*call[V]
+	errs  map[K]negative // cached load errors
+	// sweepAt is the size of errs at which expired errors are removed,
+	// so that errors for keys that are never requested again do not pile up.
+	sweepAt int
+
+	negativeTTL time.Duration
+	now         func() time.Time
+}
+
+// call is an in-flight load shared by its waiters.
+type call[V any] struct {
+	done    chan struct{} // closed when val and err are set
+	val     V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type negative struct {
+	err     error
+	expires time.Time
+}
+
+// Option configures a [Loading].
+type Option func(*options)
+
+type options struct {
+	negativeTTL time.Duration
+	now         func() time.Time
+}
+
+// WithNegativeTTL makes [Loading.GetOrLoad] cache load errors for the given time,
+// returning the cached error instead of loading the key again.
+// Context cancellation errors are never cached.
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(o *options) { o.negativeTTL = ttl }
+}
+
+// WithClock sets the function used to get the current time.
+// The default is [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
+
+// NewLoading creates a new [Loading] on top of c.
+// All accesses to c must go through the returned [Loading].
+func NewLoading[K comparable, V any](c Cache[K, V], opts ...Option) *Loading[K, V] {
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Loading[K, V]{
+		c:           c,
+		calls:       make(map[K]*call[V]),
+		errs:        make(map[K]negative),
+		sweepAt:     minSweep,
+		negativeTTL: o.negativeTTL,
+		now:         o.now,
+	}
+}
+
+// GetOrLoad returns the value for the key, loading and caching it with loader if it is missing.
+// Concurrent callers missing the same key share a single load and its result.
+//
+// A caller whose context is done stops waiting and returns the context error.
+// The load itself runs with the values, but not the cancellation, of the context of the caller that started it,
+// and is canceled only when all of its callers have stopped waiting.
+func (l *Loading[K, V]) GetOrLoad(ctx context.Context, key K, loader Loader[K, V]) (V, error) {
+	l.mu.Lock()
+	if v, ok := l.c.Get(key); ok {
+		l.mu.Unlock()
+		return v, nil
+	}
+	if n, ok := l.errs[key]; ok {
+		if l.now().Before(n.expires) {
+			l.mu.Unlock()
+			return *(new(V)), n.err
+		}
+		delete(l.errs, key)
+	}
+	c, ok := l.calls[key]
+	if !ok {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call[V]{done: make(chan struct{}), cancel: cancel}
+		l.calls[key] = c
+		go l.load(loadCtx, key, loader, c)
+	}
+	c.waiters++
+	l.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		l.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 && l.calls[key] == c {
+			// Nobody is interested in the result anymore.
+			delete(l.calls, key)
+			c.cancel()
+		}
+		l.mu.Unlock()
+		return *(new(V)), ctx.Err()
+	}
+}
+
+func (l *Loading[K, V]) load(ctx context.Context, key K, loader Loader[K, V], c *call[V]) {
+	defer c.cancel()
+	c.val, c.err = loader(ctx, key)
+
+	l.mu.Lock()
+	if l.calls[key] == c {
+		delete(l.calls, key)
+		switch {
+		case c.err == nil:
+			l.c.Put(key, c.val)
+		case l.negativeTTL > 0 && !errors.Is(c.err, context.Canceled) && !errors.Is(c.err, context.DeadlineExceeded):
+			l.addNegative(key, c.err)
+		}
+	}
+	l.mu.Unlock()
+	close(c.done)
+}
+
+const minSweep = 16
+
+func (l *Loading[K, V]) addNegative(key K, err error) {
+	now := l.now()
+	if len(l.errs) >= l.sweepAt {
+		for k, n := range l.errs {
+			if !now.Before(n.expires) {
+				delete(l.errs, k)
+			}
+		}
+		l.sweepAt = max(2*len(l.errs), minSweep)
+	}
+	l.errs[key] = negative{err: err, expires: now.Add(l.negativeTTL)}
+}
@@ -0,0 +1,199 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/denpeshkov/doodles/cache"
+	"github.com/denpeshkov/doodles/lru"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestGetOrLoadDeduplicates(t *testing.T) {
+	l := cache.NewLoading(lru.New[string, int](10))
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, error) {
+		calls.Add(1)
+		<-release
+		return len(key), nil
+	}
+
+	const n = 50
+	var wg sync.WaitGroup
+	var started sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		started.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			v, err := l.GetOrLoad(context.Background(), "key", loader)
+			if err != nil || v != 3 {
+				t.Errorf("GetOrLoad() = %d, %v, want 3, nil", v, err)
+			}
+		}()
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond) // let the callers pile up on the load
+	close(release)
+	wg.Wait()
+
+	if c := calls.Load(); c != 1 {
+		t.Errorf("loader called %d times, want 1", c)
+	}
+	// The value is cached now.
+	v, err := l.GetOrLoad(context.Background(), "key", func(context.Context, string) (int, error) {
+		t.Error("loader called for a cached key")
+		return 0, nil
+	})
+	if err != nil || v != 3 {
+		t.Errorf("GetOrLoad() = %d, %v, want 3, nil", v, err)
+	}
+}
+
+func TestGetOrLoadSharesError(t *testing.T) {
+	l := cache.NewLoading(lru.New[string, int](10))
+	errLoad := errors.New("load failed")
+	release := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, error) {
+		<-release
+		return 0, errLoad
+	}
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := l.GetOrLoad(context.Background(), "key", loader)
+			errs <- err
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for range 2 {
+		if err := <-errs; !errors.Is(err, errLoad) {
+			t.Errorf("GetOrLoad() error = %v, want %v", err, errLoad)
+		}
+	}
+}
+
+func TestGetOrLoadWaiterCancel(t *testing.T) {
+	l := cache.NewLoading(lru.New[string, int](10))
+	release := make(chan struct{})
+	var loadErr error
+	started := make(chan struct{})
+	loadDone := make(chan struct{})
+	loader := func(ctx context.Context, key string) (int, error) {
+		defer close(loadDone)
+		close(started)
+		select {
+		case <-release:
+			return 1, nil
+		case <-ctx.Done():
+			loadErr = ctx.Err()
+			return 0, ctx.Err()
+		}
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	res1 := make(chan error)
+	go func() {
+		_, err := l.GetOrLoad(ctx1, "key", loader)
+		res1 <- err
+	}()
+	<-started
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	res2 := make(chan error)
+	go func() {
+		_, err := l.GetOrLoad(ctx2, "key", loader)
+		res2 <- err
+	}()
+	for cache.Waiters(l, "key") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The caller that started the load gives up, the other one still gets the value.
+	cancel1()
+	if err := <-res1; !errors.Is(err, context.Canceled) {
+		t.Errorf("first GetOrLoad() error = %v, want %v", err, context.Canceled)
+	}
+	close(release)
+	if err := <-res2; err != nil {
+		t.Errorf("second GetOrLoad() error = %v, want nil", err)
+	}
+	<-loadDone
+	if loadErr != nil {
+		t.Errorf("load canceled with %v while a caller was waiting", loadErr)
+	}
+}
+
+func TestGetOrLoadAllWaitersCancel(t *testing.T) {
+	l := cache.NewLoading(lru.New[string, int](10))
+	loadErr := make(chan error, 1)
+	loader := func(ctx context.Context, key string) (int, error) {
+		<-ctx.Done()
+		loadErr <- ctx.Err()
+		return 0, ctx.Err()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.GetOrLoad(ctx, "key", loader); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetOrLoad() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := <-loadErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("load context error = %v, want %v", err, context.Canceled)
+	}
+
+	// A new caller starts a new load.
+	v, err := l.GetOrLoad(context.Background(), "key", func(context.Context, string) (int, error) { return 2, nil })
+	if err != nil || v != 2 {
+		t.Errorf("GetOrLoad() = %d, %v, want 2, nil", v, err)
+	}
+}
+
+func TestGetOrLoadNegativeTTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var mu sync.Mutex
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	l := cache.NewLoading(lru.New[string, int](10), cache.WithNegativeTTL(time.Second), cache.WithClock(clock))
+
+	errLoad := errors.New("not found")
+	var calls int
+	loader := func(context.Context, string) (int, error) {
+		calls++
+		return 0, errLoad
+	}
+
+	for range 3 {
+		if _, err := l.GetOrLoad(context.Background(), "key", loader); !errors.Is(err, errLoad) {
+			t.Errorf("GetOrLoad() error = %v, want %v", err, errLoad)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader called %d times within the negative TTL, want 1", calls)
+	}
+
+	mu.Lock()
+	now = now.Add(time.Second)
+	mu.Unlock()
+	l.GetOrLoad(context.Background(), "key", loader)
+	if calls != 2 {
+		t.Errorf("loader called %d times after the negative TTL, want 2", calls)
+	}
+}
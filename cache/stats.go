@@ -0,0 +1,37 @@
+package cache
+
+import "expvar"
+
+// Stats is a snapshot of cache statistics.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Puts   uint64 `json:"puts"`
+	// Evictions is the number of removed entries by removal reason.
+	Evictions map[string]uint64 `json:"evictions"`
+	Size      int               `json:"size"`
+}
+
+// HitRatio returns the ratio of hits to all lookups, or 0 if there were no lookups.
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// Publish publishes the statistics returned by f as an [expvar] variable with the given name.
+// The variable is formatted as a JSON object that also includes the hit ratio.
+// f is called on every read of the variable, possibly concurrently with the cache,
+// so it must synchronize with all other accesses to the cache.
+// Like [expvar.Publish], it panics if the name is already registered.
+func Publish(name string, f func() Stats) {
+	expvar.Publish(name, expvar.Func(func() any {
+		s := f()
+		return struct {
+			Stats
+			HitRatio float64 `json:"hit_ratio"`
+		}{s, s.HitRatio()}
+	}))
+}
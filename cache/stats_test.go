@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// published counts the variables published by the tests, whose names must be unique across runs.
+var published atomic.Int64
+
+func TestPublish(t *testing.T) {
+	name := fmt.Sprintf("%s_%d", t.Name(), published.Add(1))
+	s := Stats{Hits: 3, Misses: 1, Puts: 1, Evictions: map[string]uint64{"capacity": 2}, Size: 5}
+	Publish(name, func() Stats { return s })
+
+	var got map[string]any
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"hits":      3.0,
+		"misses":    1.0,
+		"puts":      1.0,
+		"evictions": map[string]any{"capacity": 2.0},
+		"size":      5.0,
+		"hit_ratio": 0.75,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,203 @@
+// Command cachesim replays a key trace against cache policies and reports their hit ratios.
+//
+// The trace is read from a file with one key per line, or generated.
+// Every key is looked up with Get, and on a miss it is added with Put.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/denpeshkov/doodles/arc"
+	"github.com/denpeshkov/doodles/cache"
+	"github.com/denpeshkov/doodles/lfu"
+	"github.com/denpeshkov/doodles/lru"
+	"github.com/denpeshkov/doodles/tinylfu"
+)
+
+var policies = map[string]func(capacity int) cache.Cache[string, struct{}]{
+	"arc":      func(c int) cache.Cache[string, struct{}] { return arc.New[string, struct{}](c) },
+	"lru":      func(c int) cache.Cache[string, struct{}] { return lru.New[string, struct{}](c) },
+	"lfu":      func(c int) cache.Cache[string, struct{}] { return lfu.New[string, struct{}](c) },
+	"lfu-list": func(c int) cache.Cache[string, struct{}] { return lfu.NewList[string, struct{}](c) },
+	"tinylfu":  func(c int) cache.Cache[string, struct{}] { return tinylfu.New[string, struct{}](c) },
+}
+
+// minCapacity is the smallest capacity of the policies that do not support a capacity of 1.
+var minCapacity = map[string]int{
+	"tinylfu": 2,
+}
+
+type opts struct {
+	trace      string
+	gen        string
+	n          int
+	keys       int
+	zipfS      float64
+	seed       int64
+	policies   list
+	capacities list
+	format     string
+}
+
+func (o *opts) parseFlags() {
+	o.policies = list{"lru", "lfu", "tinylfu"}
+	o.capacities = list{"1000"}
+
+	flag.StringVar(&o.trace, "trace", "", "read the trace from `FILE` with one key per line instead of generating it")
+	flag.StringVar(&o.gen, "gen", "zipf", "generate the trace with `GEN`: uniform, zipf, scan or loop")
+	flag.IntVar(&o.n, "n", 1_000_000, "generate `N` accesses")
+	flag.IntVar(&o.keys, "keys", 100_000, "generate keys from a key space of `N` keys")
+	flag.Float64Var(&o.zipfS, "zipf-s", 1.01, "the `S` parameter of the Zipf distribution, must be > 1")
+	flag.Int64Var(&o.seed, "seed", 1, "the random `SEED` of the generators")
+	flag.Var(&o.policies, "policies", "the comma separated list of `POLICIES`: arc, lru, lfu, lfu-list, tinylfu")
+	flag.Var(&o.capacities, "capacities", "the comma separated list of cache `CAPACITIES`")
+	flag.StringVar(&o.format, "format", "table", "the output `FORMAT`: table or csv")
+	flag.Parse()
+}
+
+// list is a comma separated list flag.
+type list []string
+
+func (l *list) String() string { return strings.Join(*l, ",") }
+
+func (l *list) Set(s string) error {
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+type result struct {
+	policy   string
+	capacity int
+	hits     int
+	accesses int
+	dropped  int // evicted or rejected at admission
+	elapsed  time.Duration
+}
+
+// simulate replays the trace against c.
+// Every miss adds a new entry, so the number of evicted or rejected entries
+// is the number of misses minus the final number of entries.
+// The policies do not tell the two apart, so they are reported together.
+func simulate(c cache.Cache[string, struct{}], trace []string) (hits, dropped int, elapsed time.Duration) {
+	start := time.Now()
+	for _, k := range trace {
+		if _, ok := c.Get(k); ok {
+			hits++
+			continue
+		}
+		c.Put(k, struct{}{})
+	}
+	elapsed = time.Since(start)
+	misses := len(trace) - hits
+	return hits, misses - c.Len(), elapsed
+}
+
+func write(w io.Writer, format string, results []result) error {
+	header := []string{"policy", "capacity", "hit_ratio", "evicted_or_rejected", "ops_per_sec"}
+	row := func(r result) []string {
+		return []string{
+			r.policy,
+			strconv.Itoa(r.capacity),
+			strconv.FormatFloat(float64(r.hits)/float64(r.accesses), 'f', 4, 64),
+			strconv.Itoa(r.dropped),
+			strconv.FormatFloat(float64(r.accesses)/r.elapsed.Seconds(), 'f', 0, 64),
+		}
+	}
+
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, r := range results {
+			cw.Write(row(r))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, strings.Join(header, "\t")+"\t")
+		for _, r := range results {
+			fmt.Fprintln(tw, strings.Join(row(r), "\t")+"\t")
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func main() {
+	log.SetFlags(0)
+
+	var opts opts
+	opts.parseFlags()
+
+	var capacities []int
+	for _, s := range opts.capacities {
+		c, err := strconv.Atoi(s)
+		if err != nil || c <= 0 {
+			log.Fatalf("invalid capacity %q", s)
+		}
+		capacities = append(capacities, c)
+	}
+	for _, p := range opts.policies {
+		if _, ok := policies[p]; !ok {
+			log.Fatalf("unknown policy %q", p)
+		}
+		for _, c := range capacities {
+			if c < minCapacity[p] {
+				log.Fatalf("invalid capacity %d for policy %q, must be >= %d", c, p, minCapacity[p])
+			}
+		}
+	}
+	if opts.format != "table" && opts.format != "csv" {
+		log.Fatalf("unknown format %q", opts.format)
+	}
+
+	var trace []string
+	if opts.trace != "" {
+		f, err := os.Open(opts.trace)
+		if err != nil {
+			log.Fatalf("Couldn't open the trace: %v", err)
+		}
+		trace, err = readTrace(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Couldn't read the trace: %v", err)
+		}
+	} else {
+		var err error
+		trace, err = generate(opts.gen, opts.n, opts.keys, opts.zipfS, opts.seed)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(trace) == 0 {
+		log.Fatal("empty trace")
+	}
+
+	var results []result
+	for _, p := range opts.policies {
+		for _, capacity := range capacities {
+			hits, dropped, elapsed := simulate(policies[p](capacity), trace)
+			results = append(results, result{
+				policy:   p,
+				capacity: capacity,
+				hits:     hits,
+				accesses: len(trace),
+				dropped:  dropped,
+				elapsed:  elapsed,
+			})
+		}
+	}
+	if err := write(os.Stdout, opts.format, results); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// readTrace reads a trace with one key per line, skipping empty lines.
+func readTrace(r io.Reader) ([]string, error) {
+	var trace []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if k := strings.TrimSpace(sc.Text()); k != "" {
+			trace = append(trace, k)
+		}
+	}
+	return trace, sc.Err()
+}
+
+// generate returns a trace of n accesses to a key space of the given size:
+//   - uniform: every key is equally likely;
+//   - zipf: keys follow the Zipf distribution with parameter s;
+//   - scan: every access is to a new key, the key space is ignored;
+//   - loop: the keys are accessed in order, over and over.
+func generate(gen string, n, keys int, s float64, seed int64) ([]string, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("number of accesses must be positive")
+	}
+	if keys <= 0 {
+		return nil, fmt.Errorf("key space must be positive")
+	}
+	rnd := rand.New(rand.NewSource(seed))
+
+	var next func(i int) int
+	switch gen {
+	case "uniform":
+		next = func(int) int { return rnd.Intn(keys) }
+	case "zipf":
+		if s <= 1 {
+			return nil, fmt.Errorf("zipf parameter must be > 1")
+		}
+		zipf := rand.NewZipf(rnd, s, 1, uint64(keys-1))
+		next = func(int) int { return int(zipf.Uint64()) }
+	case "scan":
+		next = func(i int) int { return i }
+	case "loop":
+		next = func(i int) int { return i % keys }
+	default:
+		return nil, fmt.Errorf("unknown generator %q", gen)
+	}
+
+	trace := make([]string, n)
+	for i := range trace {
+		trace[i] = strconv.Itoa(next(i))
+	}
+	return trace, nil
+}
@@ -0,0 +1,93 @@
+package lfu
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAgingShiftingHotSet simulates traffic whose hot set shifts to different keys.
+// Without aging the old hot keys keep their counts forever and the new hot set never gets in.
+func TestAgingShiftingHotSet(t *testing.T) {
+	const (
+		capacity = 10
+		oldBase  = 0
+		newBase  = 100
+	)
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantConverged bool
+	}{
+		{name: "no aging", wantConverged: false},
+		{name: "halving", opts: []Option{WithHalving(100)}, wantConverged: true},
+		{name: "decay", opts: []Option{WithDecay(100 * time.Millisecond), WithClock(now)}, wantConverged: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New[int, int](capacity, tt.opts...)
+			access := func(k int) bool {
+				clock = clock.Add(time.Millisecond)
+				if _, ok := l.Get(k); ok {
+					return true
+				}
+				l.Put(k, k)
+				return false
+			}
+
+			for i := range 10_000 {
+				access(oldBase + i%capacity)
+			}
+			var hits int
+			const ops = 20_000
+			for i := range ops {
+				if access(newBase+i%capacity) && i >= ops/2 {
+					hits++
+				}
+			}
+
+			var old int
+			for k := range l.m {
+				if k < newBase {
+					old++
+				}
+			}
+			if tt.wantConverged {
+				if old != 0 {
+					t.Errorf("%d old hot keys still cached", old)
+				}
+				if hits != ops/2 {
+					t.Errorf("got %d hits in the second half of the new phase, want %d", hits, ops/2)
+				}
+			} else if old != capacity-1 {
+				// One slot is taken by the newest key, the rest are pinned by the old counts.
+				t.Errorf("%d old hot keys still cached, want %d", old, capacity-1)
+			}
+		})
+	}
+}
+
+func TestDecayRescale(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New[int, int](2, WithDecay(time.Second), WithClock(func() time.Time { return clock }))
+	l.Put(1, 1)
+	l.Get(1)
+	l.Put(2, 2)
+
+	// Far enough in the future for the weight to overflow maxWeight.
+	clock = clock.Add(40 * time.Second)
+	l.Get(2)
+	if l.epoch != clock {
+		t.Fatalf("epoch = %v, want rescale at %v", l.epoch, clock)
+	}
+	l.Put(3, 3) // 1 has decayed below 2, so it is evicted
+
+	if _, ok := l.Get(1); ok {
+		t.Errorf("Get(1) hit, want evicted")
+	}
+	if _, ok := l.Get(2); !ok {
+		t.Errorf("Get(2) missed")
+	}
+}
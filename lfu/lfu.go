@@ -2,42 +2,125 @@ package lfu
 
 import (
 	"container/heap"
+	"math"
+	"time"
+
+	"github.com/denpeshkov/doodles/cache"
 )
 
+var _ cache.Cache[string, any] = (*LFU[string, any])(nil)
+
 type LFU[K comparable, V any] struct {
 	m        map[K]*entry[K, V]
 	h        minHeap[K, V]
 	ts       int // monotonically increasing counter
 	capacity int
+
+	halveEvery int // 0 if counts are never halved
+	ops        int // operations since the counts were last halved
+
+	halfLife time.Duration // 0 if counts never decay
+	now      func() time.Time
+	epoch    time.Time // time at which an access weighs 1
+
+	stats *counters // nil if statistics are disabled
 }
 
-func New[K comparable, V any](capacity int) *LFU[K, V] {
+type counters struct {
+	hits, misses, puts, evictions uint64
+}
+
+// Option configures an [LFU].
+type Option func(*options)
+
+type options struct {
+	halveEvery int
+	halfLife   time.Duration
+	now        func() time.Time
+	stats      bool
+}
+
+// WithHalving makes the cache halve the access counts of all entries every n Get and Put operations,
+// so that entries that are no longer accessed eventually lose to new ones.
+func WithHalving(n int) Option {
+	return func(o *options) { o.halveEvery = n }
+}
+
+// WithDecay makes the access counts decay exponentially with the given half-life:
+// an access made halfLife ago counts half as much as an access made now.
+func WithDecay(halfLife time.Duration) Option {
+	return func(o *options) { o.halfLife = halfLife }
+}
+
+// WithClock sets the function used to get the current time for [WithDecay].
+// The default is [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
+
+// WithStats enables collecting the statistics returned by [LFU.Stats].
+func WithStats() Option {
+	return func(o *options) { o.stats = true }
+}
+
+func New[K comparable, V any](capacity int, opts ...Option) *LFU[K, V] {
 	if capacity <= 0 {
 		panic("lfu: capacity must be > 0")
 	}
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.halveEvery < 0 {
+		panic("lfu: halving period must be >= 0")
+	}
+	if o.halfLife < 0 {
+		panic("lfu: half-life must be >= 0")
+	}
 	h := make(minHeap[K, V], 0, capacity)
 	heap.Init(&h)
-	return &LFU[K, V]{
-		m:        make(map[K]*entry[K, V]),
-		h:        h,
-		capacity: capacity,
+	l := &LFU[K, V]{
+		m:          make(map[K]*entry[K, V]),
+		h:          h,
+		capacity:   capacity,
+		halveEvery: o.halveEvery,
+		halfLife:   o.halfLife,
+		now:        o.now,
 	}
+	if l.halfLife > 0 {
+		l.epoch = l.now()
+	}
+	if o.stats {
+		l.stats = new(counters)
+	}
+	return l
 }
 
 func (l *LFU[K, V]) Get(key K) (V, bool) {
+	l.age()
 	if e, ok := l.m[key]; ok {
-		e.freq++
+		e.freq += l.weight()
 		e.ts = l.nextTs()
 		heap.Fix(&l.h, e.index)
+		if l.stats != nil {
+			l.stats.hits++
+		}
 		return e.val, true
 	}
+	if l.stats != nil {
+		l.stats.misses++
+	}
 	return *(new(V)), false
 }
 
 func (l *LFU[K, V]) Put(key K, val V) {
+	l.age()
+	if l.stats != nil {
+		l.stats.puts++
+	}
 	if e, ok := l.m[key]; ok {
 		e.val = val
-		e.freq++
+		e.freq += l.weight()
 		e.ts = l.nextTs()
 		heap.Fix(&l.h, e.index)
 		return
@@ -45,12 +128,72 @@ func (l *LFU[K, V]) Put(key K, val V) {
 	if len(l.m) >= l.capacity {
 		evicted := heap.Pop(&l.h).(*entry[K, V])
 		delete(l.m, evicted.key)
+		if l.stats != nil {
+			l.stats.evictions++
+		}
 	}
-	e := &entry[K, V]{key: key, val: val, freq: 1, ts: l.nextTs()}
+	e := &entry[K, V]{key: key, val: val, freq: l.weight(), ts: l.nextTs()}
 	l.m[key] = e
 	heap.Push(&l.h, e)
 }
 
+// Len returns the number of entries in the cache.
+func (l *LFU[K, V]) Len() int { return len(l.m) }
+
+// Stats returns a snapshot of the cache statistics.
+// Unless the cache was created with [WithStats], only the size is reported.
+// Entries are only evicted to make room for new ones, so all evictions have the "capacity" reason.
+func (l *LFU[K, V]) Stats() cache.Stats {
+	s := cache.Stats{Size: len(l.m)}
+	if l.stats != nil {
+		s.Hits = l.stats.hits
+		s.Misses = l.stats.misses
+		s.Puts = l.stats.puts
+		s.Evictions = map[string]uint64{"capacity": l.stats.evictions}
+	}
+	return s
+}
+
+// maxWeight bounds the weight of an access under decay before the counts are rescaled.
+const maxWeight = 1 << 32
+
+// weight returns the count of a single access made now.
+// Under decay, instead of decreasing all counts over time,
+// later accesses weigh exponentially more, which preserves the order of the counts.
+func (l *LFU[K, V]) weight() float64 {
+	if l.halfLife <= 0 {
+		return 1
+	}
+	now := l.now()
+	w := math.Exp2(float64(now.Sub(l.epoch)) / float64(l.halfLife))
+	if w > maxWeight {
+		// Rescale the counts relative to now to avoid overflow.
+		for _, e := range l.h {
+			e.freq /= w
+		}
+		heap.Init(&l.h) // rounding may have changed the order of close counts
+		l.epoch = now
+		w = 1
+	}
+	return w
+}
+
+// age halves all counts every halveEvery operations.
+func (l *LFU[K, V]) age() {
+	if l.halveEvery <= 0 {
+		return
+	}
+	l.ops++
+	if l.ops < l.halveEvery {
+		return
+	}
+	l.ops = 0
+	// Halving is exact for floats, so the heap order does not change.
+	for _, e := range l.h {
+		e.freq /= 2
+	}
+}
+
 func (l *LFU[K, V]) nextTs() int {
 	l.ts++
 	return l.ts
@@ -59,7 +202,7 @@ func (l *LFU[K, V]) nextTs() int {
 type entry[K comparable, V any] struct {
 	key   K
 	val   V
-	freq  int
+	freq  float64
 	ts    int // last access counter (for LRU tie-breaking)
 	index int
 }
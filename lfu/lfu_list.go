@@ -0,0 +1,141 @@
+package lfu
+
+import "github.com/denpeshkov/doodles/cache"
+
+var _ cache.Cache[string, any] = (*LFUList[string, any])(nil)
+
+// LFUList is an LFU cache with O(1) Get and Put.
+// Entries are kept in a list of frequency buckets in ascending order of frequency,
+// and every bucket holds its entries from the most to the least recently used.
+// Ties are broken the same way as in [LFU]: the least recently used entry is evicted.
+type LFUList[K comparable, V any] struct {
+	m        map[K]*listEntry[K, V]
+	freqs    bucket[K, V] // sentinel, freqs.next has the lowest frequency
+	capacity int
+}
+
+type bucket[K comparable, V any] struct {
+	freq       int
+	prev, next *bucket[K, V]
+	entries    listEntry[K, V] // sentinel, entries.next is the most recently used
+	len        int
+}
+
+type listEntry[K comparable, V any] struct {
+	key        K
+	val        V
+	prev, next *listEntry[K, V]
+	bucket     *bucket[K, V]
+}
+
+// NewList creates a new [LFUList] instance.
+func NewList[K comparable, V any](capacity int) *LFUList[K, V] {
+	if capacity <= 0 {
+		panic("lfu: capacity must be > 0")
+	}
+	l := &LFUList[K, V]{
+		m:        make(map[K]*listEntry[K, V], capacity),
+		capacity: capacity,
+	}
+	l.freqs.prev = &l.freqs
+	l.freqs.next = &l.freqs
+	return l
+}
+
+func (l *LFUList[K, V]) Get(key K) (V, bool) {
+	if e, ok := l.m[key]; ok {
+		l.touch(e)
+		return e.val, true
+	}
+	return *(new(V)), false
+}
+
+func (l *LFUList[K, V]) Put(key K, val V) {
+	if e, ok := l.m[key]; ok {
+		e.val = val
+		l.touch(e)
+		return
+	}
+	var e *listEntry[K, V]
+	if len(l.m) >= l.capacity {
+		e = l.evict() // reuse the evicted entry
+	} else {
+		e = new(listEntry[K, V])
+	}
+	e.key, e.val = key, val
+
+	b := l.freqs.next
+	if b == &l.freqs || b.freq != 1 {
+		b = l.insertBucket(1, &l.freqs)
+	}
+	b.pushFront(e)
+	l.m[key] = e
+}
+
+// Len returns the number of entries in the cache.
+func (l *LFUList[K, V]) Len() int { return len(l.m) }
+
+// touch moves the entry to the bucket with the next frequency.
+func (l *LFUList[K, V]) touch(e *listEntry[K, V]) {
+	b := e.bucket
+	next := b.next
+	if next != &l.freqs && next.freq == b.freq+1 {
+		b.remove(e)
+		if b.len == 0 {
+			l.removeBucket(b)
+		}
+		next.pushFront(e)
+		return
+	}
+	if b.len == 1 {
+		// The entry is alone in its bucket, so the bucket can just move up.
+		b.freq++
+		return
+	}
+	b.remove(e)
+	l.insertBucket(b.freq+1, b).pushFront(e)
+}
+
+// evict removes and returns the least recently used entry with the lowest frequency.
+func (l *LFUList[K, V]) evict() *listEntry[K, V] {
+	b := l.freqs.next
+	e := b.entries.prev
+	b.remove(e)
+	if b.len == 0 {
+		l.removeBucket(b)
+	}
+	delete(l.m, e.key)
+	return e
+}
+
+// insertBucket inserts a new bucket with the given frequency after at.
+func (l *LFUList[K, V]) insertBucket(freq int, at *bucket[K, V]) *bucket[K, V] {
+	b := &bucket[K, V]{freq: freq, prev: at, next: at.next}
+	b.entries.prev = &b.entries
+	b.entries.next = &b.entries
+	at.next.prev = b
+	at.next = b
+	return b
+}
+
+func (l *LFUList[K, V]) removeBucket(b *bucket[K, V]) {
+	b.prev.next = b.next
+	b.next.prev = b.prev
+	b.prev, b.next = nil, nil
+}
+
+func (b *bucket[K, V]) pushFront(e *listEntry[K, V]) {
+	e.prev = &b.entries
+	e.next = b.entries.next
+	b.entries.next.prev = e
+	b.entries.next = e
+	e.bucket = b
+	b.len++
+}
+
+func (b *bucket[K, V]) remove(e *listEntry[K, V]) {
+	e.prev.next = e.next
+	e.next.prev = e.prev
+	e.prev, e.next, e.bucket = nil, nil, nil
+	b.len--
+}
@@ -0,0 +1,165 @@
+package lfu
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/denpeshkov/doodles/cache"
+)
+
+var testGen = [...]func(capacity int) cache.Cache[int, int]{
+	func(capacity int) cache.Cache[int, int] { return New[int, int](capacity) },
+	func(capacity int) cache.Cache[int, int] { return NewList[int, int](capacity) },
+}
+
+func TestGetMissing(t *testing.T) {
+	for _, gen := range testGen {
+		c := gen(2)
+		t.Run(fmt.Sprintf("%T", c), func(t *testing.T) {
+			t.Parallel()
+
+			if v, ok := c.Get(1); ok {
+				t.Errorf("Get(1) = %d, true on empty cache", v)
+			}
+		})
+	}
+}
+
+func TestEvictLeastFrequentlyUsed(t *testing.T) {
+	for _, gen := range testGen {
+		c := gen(2)
+		t.Run(fmt.Sprintf("%T", c), func(t *testing.T) {
+			t.Parallel()
+
+			c.Put(1, 1)
+			c.Put(2, 2)
+			c.Get(1)
+			c.Get(1)
+			c.Get(2)
+			c.Put(3, 3) // evicts 2: freq(1) = 3, freq(2) = 2
+
+			if _, ok := c.Get(2); ok {
+				t.Errorf("Get(2) hit, want evicted")
+			}
+			for _, k := range []int{1, 3} {
+				if v, ok := c.Get(k); !ok || v != k {
+					t.Errorf("Get(%d) = %d, %t, want %d, true", k, v, ok, k)
+				}
+			}
+		})
+	}
+}
+
+func TestTieBreakLeastRecentlyUsed(t *testing.T) {
+	for _, gen := range testGen {
+		c := gen(3)
+		t.Run(fmt.Sprintf("%T", c), func(t *testing.T) {
+			t.Parallel()
+
+			c.Put(1, 1)
+			c.Put(2, 2)
+			c.Put(3, 3)
+			c.Get(2)
+			c.Get(1)
+			c.Get(3)
+			c.Put(4, 4) // all have freq 2, 2 is the least recently used one
+
+			if _, ok := c.Get(2); ok {
+				t.Errorf("Get(2) hit, want evicted")
+			}
+		})
+	}
+}
+
+func TestPutUpdatesValueAndFrequency(t *testing.T) {
+	for _, gen := range testGen {
+		c := gen(2)
+		t.Run(fmt.Sprintf("%T", c), func(t *testing.T) {
+			t.Parallel()
+
+			c.Put(1, 1)
+			c.Put(1, 10)
+			c.Put(2, 2)
+			c.Put(3, 3) // evicts 2: freq(1) = 2
+
+			if v, ok := c.Get(1); !ok || v != 10 {
+				t.Errorf("Get(1) = %d, %t, want 10, true", v, ok)
+			}
+			if _, ok := c.Get(2); ok {
+				t.Errorf("Get(2) hit, want evicted")
+			}
+		})
+	}
+}
+
+// TestSameEvictions checks that all implementations make the same eviction decisions.
+func TestSameEvictions(t *testing.T) {
+	const capacity = 64
+	caches := make([]cache.Cache[int, int], len(testGen))
+	for i, gen := range testGen {
+		caches[i] = gen(capacity)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rnd, 1.1, 1, 1_000)
+	for i := range 100_000 {
+		k := int(zipf.Uint64())
+		if rnd.Intn(2) == 0 {
+			for _, c := range caches {
+				c.Put(k, i)
+			}
+			continue
+		}
+		v0, ok0 := caches[0].Get(k)
+		for _, c := range caches[1:] {
+			if v, ok := c.Get(k); v != v0 || ok != ok0 {
+				t.Fatalf("op %d: %T.Get(%d) = %d, %t; %T.Get(%d) = %d, %t", i, caches[0], k, v0, ok0, c, k, v, ok)
+			}
+		}
+	}
+}
+
+func BenchmarkLFU(b *testing.B) {
+	for _, capacity := range []int{16, 256, 4096, 65536, 1 << 20} {
+		rnd := rand.New(rand.NewSource(1))
+		zipf := rand.NewZipf(rnd, 1.1, 1, uint64(4*capacity))
+		keys := make([]int, 1<<16)
+		for i := range keys {
+			keys[i] = int(zipf.Uint64())
+		}
+
+		for _, gen := range testGen {
+			c := gen(capacity)
+			// Fill the cache so that the benchmark measures the steady state.
+			for i := range capacity {
+				c.Put(i, i)
+			}
+			b.Run(fmt.Sprintf("%T/%d", c, capacity), func(b *testing.B) {
+				for i := range b.N {
+					k := keys[i%len(keys)]
+					if _, ok := c.Get(k); !ok {
+						c.Put(k, k)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	l := New[int, int](2, WithStats())
+	l.Put(1, 1)
+	l.Put(2, 2)
+	l.Get(1)
+	l.Get(3)
+	l.Put(3, 3)
+
+	want := cache.Stats{Hits: 1, Misses: 1, Puts: 3, Evictions: map[string]uint64{"capacity": 1}, Size: 2}
+	if got := l.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+	if got := New[int, int](2).Stats(); !reflect.DeepEqual(got, cache.Stats{}) {
+		t.Errorf("Stats() without WithStats = %+v, want zero", got)
+	}
+}
@@ -1,47 +1,389 @@
 package lru
 
-import "container/list"
+import (
+	"container/list"
+	"errors"
+	"iter"
+	"sync"
+	"time"
+
+	"github.com/denpeshkov/doodles/cache"
+)
+
+var (
+	_ cache.Cache[string, any] = (*LRU[string, any])(nil)
+	_ cache.Cache[string, any] = (*Sharded[string, any])(nil)
+)
+
+// ErrTooLarge is returned by [LRU.TryPut] when the cost of a value exceeds the capacity of the cache.
+var ErrTooLarge = errors.New("lru: value cost exceeds cache capacity")
+
+// EvictReason is the reason an entry was removed from an [LRU].
+type EvictReason int
+
+const (
+	EvictCapacity EvictReason = iota // removed to make room for a new entry
+	EvictExpired                     // removed because its TTL elapsed
+	EvictDeleted                     // removed by Delete or Purge
+
+	numReasons = iota
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictCapacity:
+		return "capacity"
+	case EvictExpired:
+		return "expired"
+	case EvictDeleted:
+		return "deleted"
+	}
+	return "unknown"
+}
 
 type kv[K comparable, V any] struct {
-	key K
-	val V
+	key     K
+	val     V
+	expires time.Time // zero if the entry never expires
+	cost    int64
 }
 
 type LRU[K comparable, V any] struct {
 	m        map[K]*list.Element // Element's Value is of type kv
 	l        *list.List          // List of kv
-	capacity int
+	capacity int64               // maximum total cost
+	size     int64               // total cost of all entries
+	cost     func(V) int64       // nil if every entry costs 1
+	ttl      time.Duration
+	now      func() time.Time
+	onEvict  func(K, V, EvictReason)
+	stats    *counters // nil if statistics are disabled
+	codec    Codec
 }
 
-func New[K comparable, V any](capacity int) *LRU[K, V] {
+type counters struct {
+	hits, misses, puts uint64
+	evictions          [numReasons]uint64
+}
+
+// Option configures an [LRU].
+type Option func(*options)
+
+type options struct {
+	ttl   time.Duration
+	now   func() time.Time
+	stats bool
+	codec Codec
+}
+
+// WithTTL sets the TTL of the entries added with [LRU.Put].
+// A non-positive TTL means that entries never expire, which is the default.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) { o.ttl = ttl }
+}
+
+// WithClock sets the function used to get the current time.
+// The default is [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
+
+// WithStats enables collecting the statistics returned by [LRU.Stats].
+func WithStats() Option {
+	return func(o *options) { o.stats = true }
+}
+
+// New creates a new [LRU] that holds at most capacity entries.
+func New[K comparable, V any](capacity int, opts ...Option) *LRU[K, V] {
 	if capacity <= 0 {
 		panic("lru: capacity must be positive")
 	}
-	return &LRU[K, V]{
-		m:        make(map[K]*list.Element, capacity),
+	l := newLRU[K, V](int64(capacity), nil, opts)
+	l.m = make(map[K]*list.Element, capacity)
+	return l
+}
+
+// NewWithCost creates a new [LRU] that bounds the total cost of its values, as reported by cost, by maxCost.
+func NewWithCost[K comparable, V any](maxCost int64, cost func(V) int64, opts ...Option) *LRU[K, V] {
+	if maxCost <= 0 {
+		panic("lru: max cost must be positive")
+	}
+	if cost == nil {
+		panic("lru: nil cost function")
+	}
+	l := newLRU[K, V](maxCost, cost, opts)
+	l.m = make(map[K]*list.Element)
+	return l
+}
+
+func newLRU[K comparable, V any](capacity int64, cost func(V) int64, opts []Option) *LRU[K, V] {
+	o := options{now: time.Now, codec: Gob}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	l := &LRU[K, V]{
 		l:        list.New(),
 		capacity: capacity,
+		cost:     cost,
+		ttl:      o.ttl,
+		now:      o.now,
+		codec:    o.codec,
+	}
+	if o.stats {
+		l.stats = new(counters)
 	}
+	return l
 }
 
+// Get returns the value for the key and marks it as most recently used.
+// Expired entries are removed and reported as misses.
 func (l *LRU[K, V]) Get(key K) (V, bool) {
 	if e, ok := l.m[key]; ok {
-		l.l.MoveToFront(e)
-		return e.Value.(kv[K, V]).val, true
+		if l.expired(e, l.now()) {
+			l.remove(e, EvictExpired)
+		} else {
+			l.l.MoveToFront(e)
+			if l.stats != nil {
+				l.stats.hits++
+			}
+			return e.Value.(kv[K, V]).val, true
+		}
+	}
+	if l.stats != nil {
+		l.stats.misses++
 	}
 	return *(new(V)), false
 }
 
+// Put adds the value for the key using the default TTL,
+// evicting the least recently used entries until it fits.
+// A value whose cost exceeds the capacity is not added and removes the previous value for the key;
+// use [LRU.TryPut] to detect that.
 func (l *LRU[K, V]) Put(key K, val V) {
-	if e, ok := l.m[key]; ok {
-		e.Value = kv[K, V]{key, val}
+	_ = l.put(key, val, l.ttl)
+}
+
+// PutWithTTL is like [LRU.Put], but the entry expires after the given TTL.
+// A non-positive TTL means that the entry never expires.
+func (l *LRU[K, V]) PutWithTTL(key K, val V, ttl time.Duration) {
+	_ = l.put(key, val, ttl)
+}
+
+// TryPut is like [LRU.Put], but returns [ErrTooLarge] if the value was rejected.
+func (l *LRU[K, V]) TryPut(key K, val V) error {
+	return l.put(key, val, l.ttl)
+}
+
+func (l *LRU[K, V]) put(key K, val V, ttl time.Duration) error {
+	if l.stats != nil {
+		l.stats.puts++
+	}
+	cost := int64(1)
+	if l.cost != nil {
+		cost = l.cost(val)
+		if cost < 0 {
+			panic("lru: negative cost")
+		}
+	}
+	if cost > l.capacity {
+		if e, ok := l.m[key]; ok {
+			l.remove(e, EvictCapacity)
+		}
+		return ErrTooLarge
+	}
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = l.now().Add(ttl)
+	}
+	e, ok := l.m[key]
+	if ok {
+		l.size += cost - e.Value.(kv[K, V]).cost
+		e.Value = kv[K, V]{key, val, expires, cost}
 		l.l.MoveToFront(e)
-		return
+	} else {
+		l.size += cost
+		e = l.l.PushFront(kv[K, V]{key, val, expires, cost})
+		l.m[key] = e
+	}
+	// The new entry is at the front and fits on its own, so it is never evicted here.
+	for l.size > l.capacity {
+		l.remove(l.l.Back(), EvictCapacity)
+	}
+	return nil
+}
+
+// Peek returns the value for the key without marking it as most recently used.
+func (l *LRU[K, V]) Peek(key K) (V, bool) {
+	if e, ok := l.m[key]; ok && !l.expired(e, l.now()) {
+		return e.Value.(kv[K, V]).val, true
+	}
+	return *(new(V)), false
+}
+
+// Oldest returns the least recently used unexpired entry without marking it as most recently used.
+func (l *LRU[K, V]) Oldest() (K, V, bool) {
+	now := l.now()
+	for e := l.l.Back(); e != nil; e = e.Prev() {
+		if !l.expired(e, now) {
+			v := e.Value.(kv[K, V])
+			return v.key, v.val, true
+		}
+	}
+	return *(new(K)), *(new(V)), false
+}
+
+// Contains reports whether the key is in the cache, without marking it as most recently used.
+func (l *LRU[K, V]) Contains(key K) bool {
+	_, ok := l.Peek(key)
+	return ok
+}
+
+// Delete removes the key from the cache and reports whether it was present.
+func (l *LRU[K, V]) Delete(key K) bool {
+	e, ok := l.m[key]
+	if ok {
+		l.remove(e, EvictDeleted)
+	}
+	return ok
+}
+
+// Len returns the number of entries in the cache, including expired entries not yet removed.
+func (l *LRU[K, V]) Len() int { return len(l.m) }
+
+// Cost returns the total cost of the entries in the cache.
+// For a cache created with [New] it is equal to [LRU.Len].
+func (l *LRU[K, V]) Cost() int64 { return l.size }
+
+// Resize changes the capacity of the cache, evicting the least recently used entries if needed.
+// For a cache created with [NewWithCost], capacity is the maximum total cost.
+// It returns the number of evicted entries.
+func (l *LRU[K, V]) Resize(capacity int64) int {
+	if capacity <= 0 {
+		panic("lru: capacity must be positive")
+	}
+	l.capacity = capacity
+	n := 0
+	for l.size > l.capacity {
+		l.remove(l.l.Back(), EvictCapacity)
+		n++
+	}
+	return n
+}
+
+// Purge removes all entries from the cache.
+func (l *LRU[K, V]) Purge() {
+	for e := l.l.Back(); e != nil; e = l.l.Back() {
+		l.remove(e, EvictDeleted)
+	}
+}
+
+// All returns an iterator over the unexpired entries from the most to the least recently used.
+// It does not change the recency of the entries.
+func (l *LRU[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		now := l.now()
+		for e := l.l.Front(); e != nil; {
+			next := e.Next()
+			if !l.expired(e, now) {
+				v := e.Value.(kv[K, V])
+				if !yield(v.key, v.val) {
+					return
+				}
+			}
+			e = next
+		}
+	}
+}
+
+// OnEvict sets a function called whenever an entry is removed from the cache.
+// It is not called when the value of an existing key is replaced.
+func (l *LRU[K, V]) OnEvict(f func(K, V, EvictReason)) {
+	l.onEvict = f
+}
+
+// Stats returns a snapshot of the cache statistics.
+// Unless the cache was created with [WithStats], only the size is reported.
+// Peek, Contains and All are not counted as lookups.
+func (l *LRU[K, V]) Stats() cache.Stats {
+	s := cache.Stats{Size: len(l.m)}
+	if l.stats != nil {
+		s.Hits = l.stats.hits
+		s.Misses = l.stats.misses
+		s.Puts = l.stats.puts
+		s.Evictions = make(map[string]uint64, numReasons)
+		for r, n := range l.stats.evictions {
+			s.Evictions[EvictReason(r).String()] = n
+		}
+	}
+	return s
+}
+
+// RemoveExpired removes all expired entries and returns the number of removed entries.
+func (l *LRU[K, V]) RemoveExpired() int {
+	now := l.now()
+	n := 0
+	for e := l.l.Back(); e != nil; {
+		prev := e.Prev()
+		if l.expired(e, now) {
+			l.remove(e, EvictExpired)
+			n++
+		}
+		e = prev
+	}
+	return n
+}
+
+// StartJanitor starts a goroutine that calls [LRU.RemoveExpired] every interval
+// while holding mu, which must be the lock guarding all other accesses to the LRU.
+// The returned function stops the goroutine.
+func (l *LRU[K, V]) StartJanitor(interval time.Duration, mu sync.Locker) (stop func()) {
+	return startJanitor(interval, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		l.RemoveExpired()
+	})
+}
+
+func (l *LRU[K, V]) expired(e *list.Element, now time.Time) bool {
+	exp := e.Value.(kv[K, V]).expires
+	return !exp.IsZero() && !now.Before(exp)
+}
+
+func (l *LRU[K, V]) remove(e *list.Element, reason EvictReason) {
+	v := l.l.Remove(e).(kv[K, V])
+	delete(l.m, v.key)
+	l.size -= v.cost
+	if l.stats != nil {
+		l.stats.evictions[reason]++
+	}
+	if l.onEvict != nil {
+		l.onEvict(v.key, v.val, reason)
+	}
+}
+
+func startJanitor(interval time.Duration, sweep func()) (stop func()) {
+	if interval <= 0 {
+		panic("lru: janitor interval must be positive")
 	}
-	if len(l.m) == l.capacity {
-		v := l.l.Remove(l.l.Back())
-		delete(l.m, v.(kv[K, V]).key)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
 	}
-	e := l.l.PushFront(kv[K, V]{key, val})
-	l.m[key] = e
 }
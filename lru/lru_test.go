@@ -0,0 +1,389 @@
+package lru
+
+import (
+	"errors"
+	"iter"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/denpeshkov/doodles/cache"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestEvictLeastRecentlyUsed(t *testing.T) {
+	l := New[int, int](2)
+	l.Put(1, 1)
+	l.Put(2, 2)
+	l.Get(1)
+	l.Put(3, 3)
+
+	if _, ok := l.Get(2); ok {
+		t.Errorf("Get(2) hit, want evicted")
+	}
+	for _, k := range []int{1, 3} {
+		if v, ok := l.Get(k); !ok || v != k {
+			t.Errorf("Get(%d) = %d, %t, want %d, true", k, v, ok, k)
+		}
+	}
+}
+
+func TestTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := New[string, int](10, WithTTL(time.Minute), WithClock(clock.Now))
+	l.Put("default", 1)
+	l.PutWithTTL("short", 2, time.Second)
+	l.PutWithTTL("forever", 3, 0)
+
+	tests := []struct {
+		advance time.Duration
+		key     string
+		wantOK  bool
+	}{
+		{0, "short", true},
+		{time.Second, "short", false},
+		{0, "default", true},
+		{time.Minute, "default", false},
+		{24 * time.Hour, "forever", true},
+	}
+	for _, tt := range tests {
+		clock.Advance(tt.advance)
+		if _, ok := l.Get(tt.key); ok != tt.wantOK {
+			t.Errorf("at %v: Get(%q) ok = %t, want %t", clock.Now(), tt.key, ok, tt.wantOK)
+		}
+	}
+}
+
+func TestPutRefreshesTTL(t *testing.T) {
+	clock := newFakeClock()
+	l := New[string, int](10, WithTTL(time.Minute), WithClock(clock.Now))
+	l.Put("k", 1)
+	clock.Advance(50 * time.Second)
+	l.Put("k", 2)
+	clock.Advance(50 * time.Second)
+	if v, ok := l.Get("k"); !ok || v != 2 {
+		t.Errorf("Get(k) = %d, %t, want 2, true", v, ok)
+	}
+}
+
+func TestRemoveExpired(t *testing.T) {
+	clock := newFakeClock()
+	l := New[int, int](10, WithClock(clock.Now))
+	for i := range 6 {
+		l.PutWithTTL(i, i, time.Duration(i)*time.Second)
+	}
+	clock.Advance(3 * time.Second)
+
+	// Entries 1, 2 and 3 expired; 0 never expires.
+	if n := l.RemoveExpired(); n != 3 {
+		t.Errorf("RemoveExpired() = %d, want 3", n)
+	}
+	if len(l.m) != 3 || l.l.Len() != 3 {
+		t.Errorf("got %d map entries and %d list entries, want 3", len(l.m), l.l.Len())
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	clock := newFakeClock()
+	s := NewSharded[int, int](2, 10, nil, WithTTL(time.Second), WithClock(clock.Now))
+	for i := range 4 {
+		s.Put(i, i)
+	}
+	stop := s.StartJanitor(time.Millisecond)
+	defer stop()
+
+	clock.Advance(time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := 0
+		for i := range s.shards {
+			s.shards[i].mu.Lock()
+			n += len(s.shards[i].lru.m)
+			s.shards[i].mu.Unlock()
+		}
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d entries", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+type eviction struct {
+	key    int
+	reason EvictReason
+}
+
+func TestOnEvict(t *testing.T) {
+	clock := newFakeClock()
+	l := New[int, int](2, WithClock(clock.Now))
+	var got []eviction
+	l.OnEvict(func(k, _ int, r EvictReason) { got = append(got, eviction{k, r}) })
+
+	l.Put(1, 1)
+	l.Put(2, 2)
+	l.Put(1, 10) // replacing a value is not an eviction
+	l.Put(3, 3)  // evicts 2
+	l.Delete(1)
+	l.PutWithTTL(4, 4, time.Second)
+	clock.Advance(time.Second)
+	l.Get(4)
+	l.Put(5, 5)
+	l.Purge()
+
+	want := []eviction{
+		{2, EvictCapacity},
+		{1, EvictDeleted},
+		{4, EvictExpired},
+		{3, EvictDeleted},
+		{5, EvictDeleted},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("evictions = %v, want %v", got, want)
+	}
+	if l.Len() != 0 {
+		t.Errorf("Len() = %d after Purge, want 0", l.Len())
+	}
+}
+
+func TestPeekDoesNotPromote(t *testing.T) {
+	l := New[int, int](2)
+	l.Put(1, 1)
+	l.Put(2, 2)
+	if v, ok := l.Peek(1); !ok || v != 1 {
+		t.Errorf("Peek(1) = %d, %t, want 1, true", v, ok)
+	}
+	l.Put(3, 3)
+	if l.Contains(1) {
+		t.Errorf("Contains(1) = true, want evicted despite Peek")
+	}
+	if !l.Contains(2) {
+		t.Errorf("Contains(2) = false, want true")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	l := New[int, int](2)
+	l.Put(1, 1)
+	if !l.Delete(1) {
+		t.Errorf("Delete(1) = false, want true")
+	}
+	if l.Delete(1) {
+		t.Errorf("second Delete(1) = true, want false")
+	}
+	if l.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", l.Len())
+	}
+}
+
+func TestResize(t *testing.T) {
+	l := New[int, int](5)
+	for i := range 5 {
+		l.Put(i, i)
+	}
+	if n := l.Resize(2); n != 3 {
+		t.Errorf("Resize(2) evicted %d entries, want 3", n)
+	}
+	if keys := slices.Collect(keysOf(l)); !slices.Equal(keys, []int{4, 3}) {
+		t.Errorf("keys = %v, want [4 3]", keys)
+	}
+	l.Resize(3)
+	l.Put(5, 5)
+	if keys := slices.Collect(keysOf(l)); !slices.Equal(keys, []int{5, 4, 3}) {
+		t.Errorf("keys = %v, want [5 4 3]", keys)
+	}
+}
+
+func TestAll(t *testing.T) {
+	clock := newFakeClock()
+	l := New[int, int](4, WithClock(clock.Now))
+	l.Put(1, 1)
+	l.PutWithTTL(2, 2, time.Second)
+	l.Put(3, 3)
+	l.Get(1)
+	clock.Advance(time.Second)
+
+	var keys, vals []int
+	for k, v := range l.All() {
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+	if !slices.Equal(keys, []int{1, 3}) || !slices.Equal(vals, []int{1, 3}) {
+		t.Errorf("All() = %v, %v, want [1 3], [1 3]", keys, vals)
+	}
+
+	// Deleting the yielded key during iteration is allowed.
+	for k := range l.All() {
+		l.Delete(k)
+	}
+	if l.Len() != 1 { // the expired entry is still there
+		t.Errorf("Len() = %d, want 1", l.Len())
+	}
+}
+
+func keysOf[K comparable, V any](l *LRU[K, V]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range l.All() {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+func TestCost(t *testing.T) {
+	l := NewWithCost[string, string](10, func(v string) int64 { return int64(len(v)) })
+	var evicted []string
+	l.OnEvict(func(k, _ string, _ EvictReason) { evicted = append(evicted, k) })
+
+	l.Put("a", "aaaa")
+	l.Put("b", "bbbb")
+	l.Put("c", "cc")
+	if l.Cost() != 10 {
+		t.Errorf("Cost() = %d, want 10", l.Cost())
+	}
+
+	// Needs 3 more units, so both "a" and "b" go.
+	l.Put("d", "ddddd")
+	if !slices.Equal(evicted, []string{"a", "b"}) {
+		t.Errorf("evicted = %v, want [a b]", evicted)
+	}
+	if l.Cost() != 7 || l.Len() != 2 {
+		t.Errorf("Cost() = %d, Len() = %d, want 7, 2", l.Cost(), l.Len())
+	}
+
+	// Growing an existing value evicts others, but not itself.
+	l.Put("d", "dddddddddd")
+	if l.Cost() != 10 || l.Len() != 1 {
+		t.Errorf("Cost() = %d, Len() = %d, want 10, 1", l.Cost(), l.Len())
+	}
+}
+
+func TestResizeCost(t *testing.T) {
+	const gib = 1 << 30
+	l := NewWithCost[string, int64](4*gib, func(v int64) int64 { return v })
+	l.Put("a", 2*gib)
+	l.Put("b", 2*gib)
+	if n := l.Resize(3 * gib); n != 1 {
+		t.Errorf("Resize(3GiB) evicted %d entries, want 1", n)
+	}
+	l.Resize(8 * gib)
+	l.Put("c", 6*gib)
+	if l.Len() != 2 || l.Cost() != 8*gib {
+		t.Errorf("Cost() = %d, Len() = %d, want %d, 2", l.Cost(), l.Len(), 8*gib)
+	}
+}
+
+func TestTryPutTooLarge(t *testing.T) {
+	l := NewWithCost[string, string](4, func(v string) int64 { return int64(len(v)) })
+	l.Put("a", "aa")
+	l.Put("b", "bb")
+
+	if err := l.TryPut("c", "ccccc"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("TryPut() error = %v, want %v", err, ErrTooLarge)
+	}
+	if l.Len() != 2 || l.Cost() != 4 {
+		t.Errorf("rejected value changed the cache: Len() = %d, Cost() = %d", l.Len(), l.Cost())
+	}
+
+	// A rejected value must not leave the stale value behind.
+	l.Put("a", "aaaaa")
+	if l.Contains("a") {
+		t.Errorf("Contains(a) = true, want stale value removed")
+	}
+	if err := l.TryPut("a", "aaaa"); err != nil {
+		t.Errorf("TryPut() error = %v, want nil", err)
+	}
+}
+
+func TestOldest(t *testing.T) {
+	clock := newFakeClock()
+	l := New[int, int](3, WithClock(clock.Now))
+	if _, _, ok := l.Oldest(); ok {
+		t.Errorf("Oldest() ok on empty cache")
+	}
+	l.PutWithTTL(1, 1, time.Second)
+	l.Put(2, 2)
+	l.Put(3, 3)
+	if k, _, _ := l.Oldest(); k != 1 {
+		t.Errorf("Oldest() key = %d, want 1", k)
+	}
+	clock.Advance(time.Second)
+	if k, _, _ := l.Oldest(); k != 2 {
+		t.Errorf("Oldest() key = %d, want 2 after 1 expired", k)
+	}
+	l.Oldest()
+	l.Put(4, 4) // evicts the expired 1
+	l.Put(5, 5)
+	if l.Contains(2) {
+		t.Errorf("Contains(2) = true, Oldest must not promote")
+	}
+}
+
+func TestStats(t *testing.T) {
+	clock := newFakeClock()
+	l := New[int, int](2, WithStats(), WithClock(clock.Now))
+	l.Put(1, 1)
+	l.PutWithTTL(2, 2, time.Second)
+	l.Get(1)
+	l.Get(3)
+	clock.Advance(time.Second)
+	l.Get(2) // expired
+	l.Put(3, 3)
+	l.Put(4, 4) // evicts 1
+	l.Delete(3)
+
+	want := cache.Stats{
+		Hits:      1,
+		Misses:    2,
+		Puts:      4,
+		Evictions: map[string]uint64{"capacity": 1, "expired": 1, "deleted": 1},
+		Size:      1,
+	}
+	if got := l.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestShardedStats(t *testing.T) {
+	s := NewSharded[int, int](4, 100, nil, WithStats())
+	for i := range 10 {
+		s.Put(i, i)
+		s.Get(i)
+		s.Get(i + 100)
+	}
+	got := s.Stats()
+	if got.Hits != 10 || got.Misses != 10 || got.Puts != 10 || got.Size != 10 {
+		t.Errorf("Stats() = %+v, want 10 hits, misses, puts and entries", got)
+	}
+}
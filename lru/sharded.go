@@ -0,0 +1,169 @@
+package lru
+
+import (
+	"hash/maphash"
+	"sync"
+	"time"
+
+	"github.com/denpeshkov/doodles/cache"
+)
+
+// Sharded is an [LRU] safe for concurrent use.
+// It splits the key space across independently locked [LRU] shards,
+// so that operations on keys in different shards do not contend.
+// Recency is tracked per shard, so eviction is only approximately LRU.
+type Sharded[K comparable, V any] struct {
+	shards []shard[K, V]
+	hash   func(K) uint64
+}
+
+type shard[K comparable, V any] struct {
+	mu  sync.Mutex
+	lru *LRU[K, V]
+}
+
+// NewSharded creates a new [Sharded] with the given number of shards and total capacity.
+// The capacity is split evenly across the shards, rounding up.
+// Keys are assigned to shards by hash; if hash is nil, a [maphash.Comparable] based hash is used.
+// The options are applied to every shard.
+func NewSharded[K comparable, V any](shards, capacity int, hash func(K) uint64, opts ...Option) *Sharded[K, V] {
+	if shards <= 0 {
+		panic("lru: number of shards must be positive")
+	}
+	if capacity < shards {
+		panic("lru: capacity must be at least the number of shards")
+	}
+	if hash == nil {
+		seed := maphash.MakeSeed()
+		hash = func(k K) uint64 { return maphash.Comparable(seed, k) }
+	}
+	s := &Sharded[K, V]{
+		shards: make([]shard[K, V], shards),
+		hash:   hash,
+	}
+	perShard := (capacity + shards - 1) / shards
+	for i := range s.shards {
+		s.shards[i].lru = New[K, V](perShard, opts...)
+	}
+	return s
+}
+
+func (s *Sharded[K, V]) Get(key K) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.lru.Get(key)
+}
+
+func (s *Sharded[K, V]) Put(key K, val V) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.lru.Put(key, val)
+}
+
+func (s *Sharded[K, V]) PutWithTTL(key K, val V, ttl time.Duration) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.lru.PutWithTTL(key, val, ttl)
+}
+
+func (s *Sharded[K, V]) Peek(key K) (V, bool) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.lru.Peek(key)
+}
+
+func (s *Sharded[K, V]) Contains(key K) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.lru.Contains(key)
+}
+
+func (s *Sharded[K, V]) Delete(key K) bool {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.lru.Delete(key)
+}
+
+// Len returns the total number of entries in all shards.
+func (s *Sharded[K, V]) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += sh.lru.Len()
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+func (s *Sharded[K, V]) Purge() {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		sh.lru.Purge()
+		sh.mu.Unlock()
+	}
+}
+
+// OnEvict sets a function called whenever an entry is removed from any shard.
+// The function is called with the shard locked, so it must not call back into s.
+func (s *Sharded[K, V]) OnEvict(f func(K, V, EvictReason)) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		sh.lru.OnEvict(f)
+		sh.mu.Unlock()
+	}
+}
+
+// Stats returns the sum of the statistics of all shards.
+func (s *Sharded[K, V]) Stats() cache.Stats {
+	var total cache.Stats
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		st := sh.lru.Stats()
+		sh.mu.Unlock()
+
+		total.Hits += st.Hits
+		total.Misses += st.Misses
+		total.Puts += st.Puts
+		total.Size += st.Size
+		for r, n := range st.Evictions {
+			if total.Evictions == nil {
+				total.Evictions = make(map[string]uint64, len(st.Evictions))
+			}
+			total.Evictions[r] += n
+		}
+	}
+	return total
+}
+
+// RemoveExpired removes all expired entries and returns the number of removed entries.
+// Shards are swept one at a time.
+func (s *Sharded[K, V]) RemoveExpired() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += sh.lru.RemoveExpired()
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// StartJanitor starts a goroutine that calls [Sharded.RemoveExpired] every interval.
+// The returned function stops the goroutine.
+func (s *Sharded[K, V]) StartJanitor(interval time.Duration) (stop func()) {
+	return startJanitor(interval, func() { s.RemoveExpired() })
+}
+
+func (s *Sharded[K, V]) shard(key K) *shard[K, V] {
+	return &s.shards[s.hash(key)%uint64(len(s.shards))]
+}
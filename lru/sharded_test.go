@@ -0,0 +1,113 @@
+package lru
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// mutexLRU is the single global mutex baseline for [Sharded].
+type mutexLRU[K comparable, V any] struct {
+	mu  sync.Mutex
+	lru *LRU[K, V]
+}
+
+func (m *mutexLRU[K, V]) Get(key K) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Get(key)
+}
+
+func (m *mutexLRU[K, V]) Put(key K, val V) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lru.Put(key, val)
+}
+
+type concurrentCache[K comparable, V any] interface {
+	Get(K) (V, bool)
+	Put(K, V)
+}
+
+func TestShardedGetPut(t *testing.T) {
+	// Fewer keys than the capacity of a single shard, so nothing is evicted.
+	s := NewSharded[int, int](4, 100, nil)
+	for i := range 25 {
+		s.Put(i, i*10)
+	}
+	for i := range 25 {
+		v, ok := s.Get(i)
+		if !ok {
+			t.Errorf("Get(%d) missed", i)
+			continue
+		}
+		if v != i*10 {
+			t.Errorf("Get(%d) = %d, want %d", i, v, i*10)
+		}
+	}
+}
+
+func TestShardedCustomHash(t *testing.T) {
+	// All keys go to shard 0, so the cache behaves like a single LRU of capacity 2.
+	s := NewSharded[int, int](2, 4, func(int) uint64 { return 0 })
+	s.Put(1, 1)
+	s.Put(2, 2)
+	s.Put(3, 3)
+	if _, ok := s.Get(1); ok {
+		t.Errorf("Get(1) hit, want evicted")
+	}
+	for _, k := range []int{2, 3} {
+		if _, ok := s.Get(k); !ok {
+			t.Errorf("Get(%d) missed", k)
+		}
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	s := NewSharded[int, int](8, 256, nil)
+	var wg sync.WaitGroup
+	for g := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(g)))
+			for range 10_000 {
+				k := rnd.Intn(1024)
+				if rnd.Intn(2) == 0 {
+					s.Put(k, k)
+				} else if v, ok := s.Get(k); ok && v != k {
+					t.Errorf("Get(%d) = %d", k, v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkConcurrent(b *testing.B) {
+	const capacity = 1 << 14
+	gens := []func() concurrentCache[int, int]{
+		func() concurrentCache[int, int] { return &mutexLRU[int, int]{lru: New[int, int](capacity)} },
+		func() concurrentCache[int, int] { return NewSharded[int, int](16, capacity, nil) },
+		func() concurrentCache[int, int] { return NewSharded[int, int](64, capacity, nil) },
+	}
+	for _, gen := range gens {
+		c := gen()
+		name := fmt.Sprintf("%T", c)
+		if s, ok := c.(*Sharded[int, int]); ok {
+			name = fmt.Sprintf("%s/%d", name, len(s.shards))
+		}
+		b.Run(name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				rnd := rand.New(rand.NewSource(rand.Int63()))
+				for pb.Next() {
+					k := rnd.Intn(2 * capacity)
+					if _, ok := c.Get(k); !ok {
+						c.Put(k, k)
+					}
+				}
+			})
+		})
+	}
+}
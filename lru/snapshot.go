@@ -0,0 +1,114 @@
+package lru
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Codec creates the encoders and decoders used by [LRU.Snapshot] and [LRU.Restore].
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder writes values to a stream. It is implemented by [gob.Encoder] and [json.Encoder].
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads values from a stream. It is implemented by [gob.Decoder] and [json.Decoder].
+// Decode must return [io.EOF] at the end of the stream.
+type Decoder interface {
+	Decode(v any) error
+}
+
+var (
+	// Gob encodes snapshots with [encoding/gob]. It is the default codec.
+	Gob Codec = gobCodec{}
+	// JSON encodes snapshots with [encoding/json], one entry per line.
+	JSON Codec = jsonCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// WithCodec sets the codec used by [LRU.Snapshot] and [LRU.Restore].
+// The default is [Gob].
+func WithCodec(c Codec) Option {
+	return func(o *options) { o.codec = c }
+}
+
+// snapshotEntry is the encoded form of an entry.
+type snapshotEntry[K comparable, V any] struct {
+	Key     K
+	Val     V
+	Expires time.Time // zero if the entry never expires
+}
+
+// Snapshot writes the unexpired entries of the cache to w, from the most to the least recently used.
+func (l *LRU[K, V]) Snapshot(w io.Writer) error {
+	enc := l.codec.NewEncoder(w)
+	now := l.now()
+	for e := l.l.Front(); e != nil; e = e.Next() {
+		if l.expired(e, now) {
+			continue
+		}
+		v := e.Value.(kv[K, V])
+		if err := enc.Encode(snapshotEntry[K, V]{v.key, v.val, v.expires}); err != nil {
+			return fmt.Errorf("lru: encode snapshot entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// Restore replaces the contents of the cache with the entries read from r, written by [LRU.Snapshot].
+// The entries keep their recency order and expiration time; entries that have expired since are skipped.
+// If the entries do not fit the capacity, the least recently used ones are left out, as if they were evicted.
+// Replaced entries are removed as if by [LRU.Purge].
+// On error, the cache contains the entries restored so far.
+func (l *LRU[K, V]) Restore(r io.Reader) error {
+	l.Purge()
+	dec := l.codec.NewDecoder(r)
+	now := l.now()
+	for {
+		var se snapshotEntry[K, V]
+		err := dec.Decode(&se)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("lru: decode snapshot entry: %w", err)
+		}
+		if !se.Expires.IsZero() && !now.Before(se.Expires) {
+			continue
+		}
+		if _, ok := l.m[se.Key]; ok {
+			return fmt.Errorf("lru: duplicate snapshot key %v", se.Key)
+		}
+
+		cost := int64(1)
+		if l.cost != nil {
+			if cost = l.cost(se.Val); cost < 0 {
+				return fmt.Errorf("lru: negative cost of snapshot key %v", se.Key)
+			}
+		}
+		if l.size+cost > l.capacity {
+			// This and all older entries would have been evicted.
+			return nil
+		}
+		// Entries are read from the most recently used, so they go to the back.
+		l.m[se.Key] = l.l.PushBack(kv[K, V]{se.Key, se.Val, se.Expires, cost})
+		l.size += cost
+	}
+}
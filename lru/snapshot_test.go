@@ -0,0 +1,103 @@
+package lru
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, codec := range []Codec{Gob, JSON} {
+		t.Run(fmt.Sprintf("%T", codec), func(t *testing.T) {
+			clock := newFakeClock()
+			src := New[string, int](4, WithCodec(codec), WithClock(clock.Now))
+			src.Put("a", 1)
+			src.Put("b", 2)
+			src.PutWithTTL("c", 3, time.Minute)
+			src.PutWithTTL("expired", 4, time.Second)
+			src.Get("a")
+			clock.Advance(time.Second)
+
+			var buf bytes.Buffer
+			if err := src.Snapshot(&buf); err != nil {
+				t.Fatalf("Snapshot() error = %v", err)
+			}
+			dst := New[string, int](4, WithCodec(codec), WithClock(clock.Now))
+			dst.Put("stale", 0)
+			if err := dst.Restore(&buf); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+
+			want := entries(src)
+			if got := entries(dst); !slices.Equal(got, want) {
+				t.Errorf("restored entries = %v, want %v", got, want)
+			}
+
+			// The restored cache evicts in the same order as the original one,
+			// once the original one drops its expired entry.
+			src.RemoveExpired()
+			src.Put("d", 4)
+			src.Put("e", 5)
+			dst.Put("d", 4)
+			dst.Put("e", 5)
+			if got, want := entries(dst), entries(src); !slices.Equal(got, want) {
+				t.Errorf("entries after puts = %v, want %v", got, want)
+			}
+
+			// The expiration time is preserved.
+			clock.Advance(time.Minute)
+			if _, ok := dst.Get("c"); ok {
+				t.Errorf("Get(c) hit after its TTL")
+			}
+		})
+	}
+}
+
+func TestRestoreSmallerCapacity(t *testing.T) {
+	src := New[int, int](5)
+	for i := range 5 {
+		src.Put(i, i)
+	}
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := NewWithCost[int, int](7, func(v int) int64 { return int64(v) })
+	if err := dst.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// 4 and 3 fit, 2 would exceed the capacity.
+	want := []entry{{4, 4}, {3, 3}}
+	if got := entries(dst); !slices.Equal(got, want) {
+		t.Errorf("restored entries = %v, want %v", got, want)
+	}
+	if dst.Cost() != 7 {
+		t.Errorf("Cost() = %d, want 7", dst.Cost())
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	l := New[int, int](2, WithCodec(JSON))
+	if err := l.Restore(strings.NewReader(`{"Key": "not an int"}`)); err == nil {
+		t.Errorf("Restore() error = nil, want decoding error")
+	}
+	if err := l.Restore(strings.NewReader(`{"Key": 1} {"Key": 1}`)); err == nil {
+		t.Errorf("Restore() error = nil, want duplicate key error")
+	}
+}
+
+type entry struct {
+	key, val any
+}
+
+func entries[K comparable, V any](l *LRU[K, V]) []entry {
+	var es []entry
+	for k, v := range l.All() {
+		es = append(es, entry{k, v})
+	}
+	return es
+}
@@ -0,0 +1,180 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AckConfig configures the acknowledgement mode of a subscription. See [WithAck].
+type AckConfig struct {
+	// VisibilityTimeout is how long a delivered message may stay unacknowledged before it is redelivered.
+	VisibilityTimeout time.Duration
+	// MaxAttempts is the number of deliveries after which an unacknowledged message is dead-lettered.
+	// Zero means no limit.
+	MaxAttempts int
+	// DeadLetter is the topic that dead-lettered messages are published to. If empty, they are dropped.
+	DeadLetter string
+}
+
+// WithAck makes the subscription deliver messages at least once: every message must be acknowledged
+// with [Message.Ack], or it is redelivered after the visibility timeout or a [Message.Nack].
+// A redelivery that does not fit the buffer of the subscription is dropped, and counts as an attempt.
+//
+// Dead-lettered messages are published to the dead-letter topic like other messages,
+// except that they never wait for room in a buffer: a subscription with the [Block] policy
+// and a full buffer drops them, counting them in [Subscription.Dropped].
+func WithAck(cfg AckConfig) SubscribeOption {
+	return func(o *subOptions) { o.ack = &cfg }
+}
+
+// Clock schedules functions, so that tests can control the time.
+type Clock interface {
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a timer scheduled by a [Clock]. It is implemented by [time.Timer].
+type Timer interface {
+	Stop() bool
+}
+
+// WithClock sets the clock of the visibility timeouts.
+// The default uses [time.AfterFunc].
+func WithClock(c Clock) Option {
+	return func(o *options) { o.clock = c }
+}
+
+type realClock struct{}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer { return time.AfterFunc(d, f) }
+
+// delivery identifies a message delivered in the acknowledgement mode.
+type delivery struct {
+	id     uint64
+	settle func(id uint64, ack bool) error
+}
+
+// pending is a delivered message waiting to be acknowledged.
+type pending[T any] struct {
+	sub   Subscription[T]
+	msg   Message[T]
+	timer Timer
+}
+
+// Ack acknowledges the message, so that it is not redelivered.
+// It returns an error if the subscription is not in the acknowledgement mode.
+// Acknowledging a message again, or after it was dead-lettered, does nothing.
+func (m Message[T]) Ack() error {
+	if m.delivery == nil {
+		return errNoAck
+	}
+	return m.delivery.settle(m.delivery.id, true)
+}
+
+// Nack tells that the message was not processed, so that it is redelivered now,
+// or dead-lettered if it was delivered MaxAttempts times.
+func (m Message[T]) Nack() error {
+	if m.delivery == nil {
+		return errNoAck
+	}
+	return m.delivery.settle(m.delivery.id, false)
+}
+
+var errNoAck = errors.New("subscription not in the acknowledgement mode")
+
+func checkAck(cfg *AckConfig) error {
+	if cfg.VisibilityTimeout <= 0 {
+		return errors.New("visibility timeout must be positive")
+	}
+	if cfg.MaxAttempts < 0 {
+		return errors.New("max attempts must be >= 0")
+	}
+	if cfg.DeadLetter != "" {
+		if err := checkTopic(cfg.DeadLetter); err != nil {
+			return fmt.Errorf("dead letter: %w", err)
+		}
+	}
+	return nil
+}
+
+// track makes the message pending for the subscription, returning it with its delivery.
+// It is called in the Run loop.
+func (ps *PubSub[T]) track(sub Subscription[T], m Message[T]) Message[T] {
+	ps.nextID++
+	m.Attempt = 1
+	m.delivery = &delivery{id: ps.nextID, settle: ps.settle}
+	p := &pending[T]{sub: sub, msg: m}
+	ps.pending[m.delivery.id] = p
+	ps.startTimer(p)
+	return m
+}
+
+func (ps *PubSub[T]) startTimer(p *pending[T]) {
+	id, attempt := p.msg.delivery.id, p.msg.Attempt
+	p.timer = ps.o.clock.AfterFunc(p.sub.state.ack.VisibilityTimeout, func() {
+		_ = ps.process(func() {
+			if p, ok := ps.pending[id]; ok && p.msg.Attempt == attempt {
+				ps.retry(p)
+			}
+		})
+	})
+}
+
+func (ps *PubSub[T]) settle(id uint64, ack bool) error {
+	return ps.process(func() {
+		p, ok := ps.pending[id]
+		if !ok {
+			return
+		}
+		p.timer.Stop()
+		if ack {
+			delete(ps.pending, id)
+			return
+		}
+		ps.retry(p)
+	})
+}
+
+// retry redelivers the message, or dead-letters it if it was delivered too many times.
+// It is called in the Run loop.
+func (ps *PubSub[T]) retry(p *pending[T]) {
+	cfg := p.sub.state.ack
+	if cfg.MaxAttempts > 0 && p.msg.Attempt >= cfg.MaxAttempts {
+		delete(ps.pending, p.msg.delivery.id)
+		if cfg.DeadLetter == "" {
+			p.sub.state.dropped.Add(1)
+			return
+		}
+		data, err := ps.encode(p.msg.Value)
+		if err == nil {
+			_, err = ps.publish(context.Background(), Message[T]{Topic: cfg.DeadLetter, Value: p.msg.Value}, data, false)
+		}
+		if err != nil {
+			p.sub.state.dropped.Add(1)
+		}
+		return
+	}
+
+	p.msg.Attempt++
+	select {
+	case p.sub.ch <- p.msg:
+	default:
+		p.sub.state.dropped.Add(1)
+	}
+	ps.startTimer(p)
+}
+
+// untrack drops the pending messages of the subscription.
+// It is called in the Run loop.
+func (ps *PubSub[T]) untrack(sub Subscription[T]) {
+	if sub.state.ack == nil {
+		return
+	}
+	for id, p := range ps.pending {
+		if p.sub == sub {
+			p.timer.Stop()
+			delete(ps.pending, id)
+		}
+	}
+}
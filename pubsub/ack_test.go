@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const visibilityTimeout = 10 * time.Second
+
+func startAck(t *testing.T, opts ...Option) (*PubSub[string], *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return start[string](t, append(opts, WithClock(clock))...), clock
+}
+
+func TestAckRedelivery(t *testing.T) {
+	ps, clock := startAck(t)
+	sub, err := ps.Subscribe("orders", 10, WithAck(AckConfig{VisibilityTimeout: visibilityTimeout, MaxAttempts: 3, DeadLetter: "dead"}))
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	dead, _ := ps.Subscribe("dead", 10)
+	publish(t, ps, "orders", "a")
+
+	if m := next(t, sub); m.Value != "a" || m.Attempt != 1 {
+		t.Fatalf("received %q attempt %d, want %q attempt 1", m.Value, m.Attempt, "a")
+	}
+	clock.Advance(visibilityTimeout) // not acknowledged in time
+	m := next(t, sub)
+	if m.Value != "a" || m.Attempt != 2 {
+		t.Fatalf("received %q attempt %d, want %q attempt 2", m.Value, m.Attempt, "a")
+	}
+	if err := m.Nack(); err != nil {
+		t.Fatalf("Nack() error = %v", err)
+	}
+	if m := next(t, sub); m.Attempt != 3 {
+		t.Fatalf("received attempt %d after Nack, want 3", m.Attempt)
+	}
+
+	clock.Advance(visibilityTimeout) // the last attempt
+	if m := next(t, dead); m.Value != "a" || m.Topic != "dead" {
+		t.Errorf("dead letter %q to %q, want %q to %q", m.Value, m.Topic, "a", "dead")
+	}
+	clock.Advance(visibilityTimeout)
+	barrier(ps)
+	if got := received(sub); len(got) != 0 {
+		t.Errorf("received %q after the dead letter", got)
+	}
+}
+
+func TestAck(t *testing.T) {
+	ps, clock := startAck(t)
+	sub, _ := ps.Subscribe("orders", 10, WithAck(AckConfig{VisibilityTimeout: visibilityTimeout}))
+	publish(t, ps, "orders", "a", "b")
+
+	if err := next(t, sub).Ack(); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	next(t, sub)
+	clock.Advance(visibilityTimeout)
+	barrier(ps)
+	if got := received(sub); len(got) != 1 || got[0] != "b" {
+		t.Errorf("redelivered %q, want only %q", got, "b")
+	}
+}
+
+func TestAckDropsWithoutDeadLetter(t *testing.T) {
+	ps, clock := startAck(t)
+	sub, _ := ps.Subscribe("orders", 10, WithAck(AckConfig{VisibilityTimeout: visibilityTimeout, MaxAttempts: 1}))
+	publish(t, ps, "orders", "a")
+	next(t, sub)
+	clock.Advance(visibilityTimeout)
+	barrier(ps)
+	if sub.Dropped() != 1 {
+		t.Errorf("Dropped() = %d, want 1", sub.Dropped())
+	}
+}
+
+func TestAckDeadLetterDoesNotBlock(t *testing.T) {
+	ps, clock := startAck(t)
+	sub, _ := ps.Subscribe("orders", 10, WithAck(AckConfig{VisibilityTimeout: visibilityTimeout, MaxAttempts: 1, DeadLetter: "dead"}))
+	dead, _ := ps.Subscribe("dead", 0) // never read
+	publish(t, ps, "orders", "a")
+	next(t, sub)
+	clock.Advance(visibilityTimeout)
+
+	barrier(ps) // returns only if the Run loop is not blocked
+	if dead.Dropped() != 1 {
+		t.Errorf("Dropped() = %d for the dead-letter subscription, want 1", dead.Dropped())
+	}
+	publish(t, ps, "orders", "b")
+	if m := next(t, sub); m.Value != "b" {
+		t.Errorf("received %q, want %q", m.Value, "b")
+	}
+}
+
+func TestAckReplay(t *testing.T) {
+	ps, clock := startAck(t, WithLog(openLog(t, t.TempDir()), JSON))
+	publish(t, ps, "orders", "a")
+	sub, _ := ps.Subscribe("orders", 10, StartAt(Earliest), WithAck(AckConfig{VisibilityTimeout: visibilityTimeout}))
+
+	if m := next(t, sub); m.Value != "a" || m.Attempt != 1 {
+		t.Fatalf("replayed %q attempt %d, want %q attempt 1", m.Value, m.Attempt, "a")
+	}
+	clock.Advance(visibilityTimeout)
+	if m := next(t, sub); m.Value != "a" || m.Attempt != 2 {
+		t.Fatalf("received %q attempt %d, want %q attempt 2", m.Value, m.Attempt, "a")
+	}
+}
+
+func TestAckErrors(t *testing.T) {
+	ps, _ := startAck(t)
+	for _, cfg := range []AckConfig{{}, {VisibilityTimeout: time.Second, MaxAttempts: -1}, {VisibilityTimeout: time.Second, DeadLetter: "dead.*"}} {
+		if _, err := ps.Subscribe("orders", 1, WithAck(cfg)); err == nil {
+			t.Errorf("Subscribe() with %+v error = nil", cfg)
+		}
+	}
+
+	sub, _ := ps.Subscribe("orders", 1)
+	publish(t, ps, "orders", "a")
+	if err := next(t, sub).Ack(); !errors.Is(err, errNoAck) {
+		t.Errorf("Ack() error = %v, want %v", err, errNoAck)
+	}
+}
@@ -0,0 +1,459 @@
+// Package client implements a client of the pubsub server of package server.
+//
+// The client reconnects when the connection is lost, and subscribes again to its subscriptions.
+// Messages published while it is disconnected are not received.
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/denpeshkov/doodles/pubsub"
+	"github.com/denpeshkov/doodles/pubsub/internal/wire"
+)
+
+var (
+	// ErrClosed is returned by the methods of a closed [Client].
+	ErrClosed = errors.New("client closed")
+	// ErrConnLost is returned by a request whose connection was lost before the reply,
+	// so that it is not known whether the server handled it.
+	ErrConnLost = errors.New("connection lost")
+)
+
+// Client is a client of a pubsub server. It is safe for concurrent use.
+type Client[T any] struct {
+	addr  string
+	codec pubsub.Codec
+	o     options
+
+	ctx    context.Context // canceled by Close
+	cancel context.CancelFunc
+	done   chan struct{} // closed when run returns
+
+	mu     sync.Mutex
+	conn   *conn         // nil while reconnecting
+	ready  chan struct{} // closed when conn is set
+	subs   map[uint64]*Subscription[T]
+	nextID uint64 // of the last request or subscription
+}
+
+// Option configures a [Client].
+type Option func(*options)
+
+type options struct {
+	heartbeat  time.Duration
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// WithHeartbeat sets the heartbeat interval. The default is 10 seconds.
+func WithHeartbeat(d time.Duration) Option {
+	return func(o *options) { o.heartbeat = d }
+}
+
+// WithBackoff sets the delay between reconnection attempts, doubling from min up to max.
+// The defaults are 100 milliseconds and 5 seconds.
+func WithBackoff(min, max time.Duration) Option {
+	return func(o *options) {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// conn is a connection to the server.
+type conn struct {
+	nc      net.Conn
+	wmu     sync.Mutex
+	pending map[uint64]chan error // replies by request ID, guarded by Client.mu
+	lost    chan struct{}         // closed when the connection is lost
+}
+
+// Subscription is a subscription of a [Client].
+// When its buffer is full, the oldest buffered message is dropped to make room for a new one,
+// like with [pubsub.DropOldest], so that a slow consumer never stalls the client.
+type Subscription[T any] struct {
+	id      uint64
+	pattern string
+	ch      chan pubsub.Message[T]
+	dropped atomic.Uint64
+
+	mu     sync.Mutex
+	closed bool
+	err    error // set before the channel is closed
+}
+
+// Pattern returns the topic pattern of the subscription.
+func (s *Subscription[T]) Pattern() string { return s.pattern }
+
+// Updates returns a channel to receive messages from the server.
+// It is closed by [Client.Unsubscribe] and [Client.Close].
+func (s *Subscription[T]) Updates() <-chan pubsub.Message[T] { return s.ch }
+
+// Err returns the reason the subscription was closed, such as the server rejecting it
+// when the client subscribes again on reconnection, once the channel returned by Updates is closed.
+// It returns nil if it was closed by [Client.Unsubscribe] or [Client.Close].
+func (s *Subscription[T]) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Dropped returns the number of messages dropped because the buffer was full.
+func (s *Subscription[T]) Dropped() uint64 { return s.dropped.Load() }
+
+// deliver sends the message without blocking, dropping the oldest one if the buffer is full.
+// It is called from the read loop, the only sender to the channel.
+func (s *Subscription[T]) deliver(m pubsub.Message[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- m:
+		return
+	default:
+	}
+	s.dropped.Add(1)
+	select {
+	case <-s.ch:
+	default: // the consumer emptied the buffer in the meantime
+	}
+	select {
+	case s.ch <- m:
+	default: // an unbuffered subscription with no receiver ready
+	}
+}
+
+func (s *Subscription[T]) close(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.err = err
+		close(s.ch)
+	}
+}
+
+// Dial connects to the server at the address, encoding the messages with the codec.
+func Dial[T any](ctx context.Context, addr string, codec pubsub.Codec, opts ...Option) (*Client[T], error) {
+	o := options{heartbeat: 10 * time.Second, minBackoff: 100 * time.Millisecond, maxBackoff: 5 * time.Second}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &Client[T]{
+		addr:  addr,
+		codec: codec,
+		o:     o,
+		done:  make(chan struct{}),
+		ready: make(chan struct{}),
+		subs:  make(map[uint64]*Subscription[T]),
+	}
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.setConn(cn) // cannot be closed yet
+	go c.run(cn)
+	return c, nil
+}
+
+// Subscribe subscribes to the topics matching the pattern, with the specified buffer size.
+// If the connection is lost before the server replies, the subscription is made again on reconnection.
+// If the server rejects it then, it is closed with the error reported by [Subscription.Err].
+func (c *Client[T]) Subscribe(ctx context.Context, pattern string, bufSize int) (*Subscription[T], error) {
+	sub := &Subscription[T]{pattern: pattern, ch: make(chan pubsub.Message[T], bufSize)}
+	err := c.request(ctx, wire.Frame{Type: wire.Subscribe, Topic: pattern}, func(f *wire.Frame) {
+		c.nextID++
+		sub.id = c.nextID
+		f.Sub = sub.id
+		c.subs[sub.id] = sub
+	})
+	if err != nil && !errors.Is(err, ErrConnLost) {
+		c.mu.Lock()
+		delete(c.subs, sub.id)
+		c.mu.Unlock()
+		if sub.id != 0 && ctx.Err() != nil {
+			// The server may have subscribed after we stopped waiting.
+			c.send(wire.Frame{Type: wire.Unsubscribe, Sub: sub.id})
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Unsubscribe removes the subscription and closes its channel.
+// If ctx is canceled before the server replies, the request is sent again without waiting for the reply.
+func (c *Client[T]) Unsubscribe(ctx context.Context, sub *Subscription[T]) error {
+	c.mu.Lock()
+	_, ok := c.subs[sub.id]
+	delete(c.subs, sub.id)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	sub.close(nil)
+	err := c.request(ctx, wire.Frame{Type: wire.Unsubscribe, Sub: sub.id}, nil)
+	switch {
+	case errors.Is(err, ErrConnLost):
+		return nil // the server forgot the subscription with the connection
+	case err != nil && ctx.Err() != nil:
+		c.send(wire.Frame{Type: wire.Unsubscribe, Sub: sub.id})
+		return nil
+	}
+	return err
+}
+
+// Publish publishes the message to the topic, waiting for the server to deliver it.
+// It waits for the client to reconnect if needed.
+func (c *Client[T]) Publish(ctx context.Context, topic string, msg T) error {
+	data, err := c.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.request(ctx, wire.Frame{Type: wire.Publish, Topic: topic, Data: data}, nil)
+}
+
+// Close closes the connection and the subscriptions.
+func (c *Client[T]) Close() error {
+	c.cancel()
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.nc.Close()
+	}
+	for id, sub := range c.subs {
+		sub.close(nil)
+		delete(c.subs, id)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return nil
+}
+
+// request sends the request and waits for the reply, waiting for a connection if needed.
+// prepare is called with the frame under the lock before it is sent.
+func (c *Client[T]) request(ctx context.Context, f wire.Frame, prepare func(f *wire.Frame)) error {
+	for {
+		c.mu.Lock()
+		if c.ctx.Err() != nil {
+			c.mu.Unlock()
+			return ErrClosed
+		}
+		cn, ready := c.conn, c.ready
+		if cn == nil {
+			c.mu.Unlock()
+			select {
+			case <-ready:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-c.ctx.Done():
+				return ErrClosed
+			}
+		}
+		c.nextID++
+		f.ID = c.nextID
+		reply := make(chan error, 1)
+		cn.pending[f.ID] = reply
+		if prepare != nil {
+			prepare(&f)
+		}
+		c.mu.Unlock()
+
+		if err := c.write(cn, f); err != nil {
+			cn.nc.Close() // the read loop fails the pending requests
+		}
+		select {
+		case err := <-reply:
+			return err
+		case <-ctx.Done():
+			c.mu.Lock()
+			delete(cn.pending, f.ID)
+			c.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *Client[T]) dial(ctx context.Context) (*conn, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{nc: nc, pending: make(map[uint64]chan error), lost: make(chan struct{})}, nil
+}
+
+// send sends the request without waiting for the reply, which is ignored.
+// Without a connection, it does nothing.
+func (c *Client[T]) send(f wire.Frame) {
+	c.mu.Lock()
+	cn := c.conn
+	c.nextID++
+	f.ID = c.nextID
+	c.mu.Unlock()
+	if cn == nil {
+		return
+	}
+	if err := c.write(cn, f); err != nil {
+		cn.nc.Close()
+	}
+}
+
+// setConn makes the connection current, subscribing to the subscriptions.
+// If the client was closed, it closes the connection instead and reports false.
+func (c *Client[T]) setConn(cn *conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		cn.nc.Close()
+		return false
+	}
+	for _, sub := range c.subs {
+		c.nextID++
+		reply := make(chan error, 1)
+		cn.pending[c.nextID] = reply
+		go c.resubscribed(sub, reply)
+		if err := c.write(cn, wire.Frame{Type: wire.Subscribe, ID: c.nextID, Sub: sub.id, Topic: sub.pattern}); err != nil {
+			cn.nc.Close() // the read loop fails the pending requests
+			break
+		}
+	}
+	c.conn = cn
+	close(c.ready)
+	go c.heartbeat(cn)
+	return true
+}
+
+// resubscribed closes the subscription if the server rejected it on reconnection.
+func (c *Client[T]) resubscribed(sub *Subscription[T], reply <-chan error) {
+	err := <-reply
+	if err == nil || errors.Is(err, ErrConnLost) {
+		return // subscribed, or to be subscribed again on the next connection
+	}
+	c.mu.Lock()
+	ok := c.subs[sub.id] == sub
+	if ok {
+		delete(c.subs, sub.id)
+	}
+	c.mu.Unlock()
+	if ok {
+		sub.close(err)
+	}
+}
+
+// run reads from the connection, and reconnects when it is lost, until the client is closed.
+func (c *Client[T]) run(cn *conn) {
+	defer close(c.done)
+	for {
+		c.read(cn)
+		cn.nc.Close()
+		close(cn.lost)
+
+		c.mu.Lock()
+		c.conn = nil
+		c.ready = make(chan struct{})
+		for id, reply := range cn.pending {
+			reply <- ErrConnLost
+			delete(cn.pending, id)
+		}
+		c.mu.Unlock()
+
+		if cn = c.reconnect(); cn == nil || !c.setConn(cn) {
+			return
+		}
+	}
+}
+
+// reconnect dials the server until it succeeds or the client is closed.
+func (c *Client[T]) reconnect() *conn {
+	backoff := c.o.minBackoff
+	for {
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-c.ctx.Done():
+			t.Stop()
+			return nil
+		}
+		cn, err := c.dial(c.ctx)
+		if err == nil {
+			return cn
+		}
+		backoff = min(2*backoff, c.o.maxBackoff)
+	}
+}
+
+func (c *Client[T]) read(cn *conn) {
+	br := bufio.NewReader(cn.nc)
+	for {
+		cn.nc.SetReadDeadline(time.Now().Add(3 * c.o.heartbeat))
+		f, err := wire.Read(br)
+		if err != nil {
+			return
+		}
+		switch f.Type {
+		case wire.Ping:
+			if err := c.write(cn, wire.Frame{Type: wire.Pong}); err != nil {
+				return
+			}
+		case wire.Pong:
+		case wire.Reply:
+			c.mu.Lock()
+			reply, ok := cn.pending[f.ID]
+			delete(cn.pending, f.ID)
+			c.mu.Unlock()
+			if !ok {
+				continue
+			}
+			var err error
+			if len(f.Data) > 0 {
+				err = errors.New(string(f.Data))
+			}
+			reply <- err
+		case wire.Message:
+			c.mu.Lock()
+			sub, ok := c.subs[f.ID]
+			c.mu.Unlock()
+			if !ok {
+				continue // unsubscribed
+			}
+			m := pubsub.Message[T]{Topic: f.Topic, Offset: f.Offset}
+			if err := c.codec.Unmarshal(f.Data, &m.Value); err != nil {
+				continue
+			}
+			sub.deliver(m)
+		default:
+			return // not speaking the protocol
+		}
+	}
+}
+
+func (c *Client[T]) heartbeat(cn *conn) {
+	t := time.NewTicker(c.o.heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.write(cn, wire.Frame{Type: wire.Ping}); err != nil {
+				cn.nc.Close()
+				return
+			}
+		case <-cn.lost:
+			return
+		}
+	}
+}
+
+func (c *Client[T]) write(cn *conn, f wire.Frame) error {
+	cn.wmu.Lock()
+	defer cn.wmu.Unlock()
+	cn.nc.SetWriteDeadline(time.Now().Add(3 * c.o.heartbeat))
+	return wire.Write(cn.nc, f)
+}
@@ -0,0 +1,475 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denpeshkov/doodles/pubsub"
+	"github.com/denpeshkov/doodles/pubsub/internal/wire"
+	"github.com/denpeshkov/doodles/pubsub/server"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// startPubSub runs a new PubSub until the test ends.
+func startPubSub(t *testing.T) *pubsub.PubSub[string] {
+	t.Helper()
+	ps := pubsub.NewPubSub[string]()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ps.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return ps
+}
+
+// serve serves the PubSub on the address, returning the address and a function to stop serving.
+func serve(t *testing.T, ps *pubsub.PubSub[string], addr string) (string, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.New(ps, pubsub.JSON, server.WithHeartbeat(50*time.Millisecond)).Serve(ctx, ln)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return ln.Addr().String(), stop
+}
+
+func dial(t *testing.T, addr string) *Client[string] {
+	t.Helper()
+	c, err := Dial[string](context.Background(), addr, pubsub.JSON, WithHeartbeat(50*time.Millisecond), WithBackoff(10*time.Millisecond, 50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func next(t *testing.T, sub *Subscription[string]) pubsub.Message[string] {
+	t.Helper()
+	select {
+	case m, ok := <-sub.Updates():
+		if !ok {
+			t.Fatalf("subscription closed")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received")
+	}
+	panic("unreachable")
+}
+
+// waitSubscriptions waits for the PubSub to have n subscriptions.
+func waitSubscriptions(t *testing.T, ps *pubsub.PubSub[string], n int) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		var got int
+		for range ps.Subscriptions() {
+			got++
+		}
+		if got == n {
+			return
+		}
+	}
+	t.Fatalf("the PubSub does not have %d subscriptions", n)
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	ctx := context.Background()
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+
+	sub, err := c.Subscribe(ctx, "orders.*", 10)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if err := c.Publish(ctx, "orders.created", "a"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if m := next(t, sub); m.Topic != "orders.created" || m.Value != "a" {
+		t.Errorf("received %q to %q, want %q to %q", m.Value, m.Topic, "a", "orders.created")
+	}
+	if err := ps.Publish(ctx, "orders.deleted", "b"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if m := next(t, sub); m.Value != "b" {
+		t.Errorf("received %q, want %q", m.Value, "b")
+	}
+
+	if err := c.Publish(ctx, "orders.*", "c"); err == nil || !strings.Contains(err.Error(), "invalid topic") {
+		t.Errorf("Publish() error = %v, want an invalid topic", err)
+	}
+	if _, err := c.Subscribe(ctx, "orders..created", 1); err == nil {
+		t.Errorf("Subscribe() error = nil, want an invalid pattern")
+	}
+
+	if err := c.Unsubscribe(ctx, sub); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	if _, ok := <-sub.Updates(); ok {
+		t.Errorf("Updates() is not closed after Unsubscribe")
+	}
+	waitSubscriptions(t, ps, 0)
+}
+
+func TestReconnect(t *testing.T) {
+	ctx := context.Background()
+	ps := startPubSub(t)
+	addr, stop := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, err := c.Subscribe(ctx, "orders", 10)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	waitSubscriptions(t, ps, 1)
+
+	stop()
+	waitSubscriptions(t, ps, 0)
+	// Wait for the client to notice, or the publication could be sent on the lost connection.
+	for {
+		c.mu.Lock()
+		lost := c.conn == nil
+		c.mu.Unlock()
+		if lost {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	published := make(chan error)
+	go func() {
+		// Waits for the client to reconnect.
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		published <- c.Publish(ctx, "orders", "a")
+	}()
+	serve(t, ps, addr)
+
+	if err := <-published; err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if m := next(t, sub); m.Value != "a" {
+		t.Errorf("received %q after the reconnection, want %q", m.Value, "a")
+	}
+}
+
+func TestHeartbeatKeepsConnection(t *testing.T) {
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, _ := c.Subscribe(context.Background(), "orders", 10)
+	waitSubscriptions(t, ps, 1)
+
+	time.Sleep(300 * time.Millisecond) // 6 heartbeat intervals without messages
+	if err := ps.Publish(context.Background(), "orders", "a"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if m := next(t, sub); m.Value != "a" {
+		t.Errorf("received %q, want %q", m.Value, "a")
+	}
+}
+
+func TestClose(t *testing.T) {
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, _ := c.Subscribe(context.Background(), "orders", 10)
+	c.Close()
+
+	if _, ok := <-sub.Updates(); ok {
+		t.Errorf("Updates() is not closed after Close")
+	}
+	if err := c.Publish(context.Background(), "orders", "a"); err != ErrClosed {
+		t.Errorf("Publish() error = %v, want %v", err, ErrClosed)
+	}
+	waitSubscriptions(t, ps, 0)
+}
+
+// waitDropped waits for the subscription to have dropped at least n messages.
+func waitDropped(t *testing.T, sub *Subscription[string], n uint64) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); sub.Dropped() < n; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Dropped() = %d, want at least %d", sub.Dropped(), n)
+		}
+	}
+}
+
+func TestSlowSubscription(t *testing.T) {
+	ctx := context.Background()
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, _ := c.Subscribe(ctx, "orders", 1)
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := c.Publish(ctx, "orders", msg); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	next(t, sub)
+
+	// A consumer that publishes through the same client with a full buffer.
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := c.Publish(ctx, "orders", "d"); err != nil {
+		t.Fatalf("Publish() error = %v with a full subscription", err)
+	}
+	// Four messages, one read and one buffered.
+	waitDropped(t, sub, 2)
+	if m := next(t, sub); m.Value != "d" {
+		t.Errorf("received %q, want the newest message %q", m.Value, "d")
+	}
+}
+
+func TestCloseWithFullSubscription(t *testing.T) {
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, _ := c.Subscribe(context.Background(), "orders", 1)
+	waitSubscriptions(t, ps, 1)
+	for _, msg := range []string{"a", "b"} {
+		if err := ps.Publish(context.Background(), "orders", msg); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	waitDropped(t, sub, 1)
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		c.Close()
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Close() did not return")
+	}
+}
+
+func TestRequestCanceled(t *testing.T) {
+	// A server that never replies.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer nc.Close()
+		io.Copy(io.Discard, nc)
+	}()
+	c, err := Dial[string](context.Background(), ln.Addr().String(), pubsub.JSON)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Publish(ctx, "orders", "a"); err != context.DeadlineExceeded {
+		t.Errorf("Publish() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	c.mu.Lock()
+	n := len(c.conn.pending)
+	c.mu.Unlock()
+	if n != 0 {
+		t.Errorf("%d pending requests after the cancellation, want 0", n)
+	}
+}
+
+// fakeServer serves on the address until the test ends, sending the frames it reads to the returned channel.
+// It replies to a frame with the error returned by handle, if handle reports true.
+func fakeServer(t *testing.T, addr string, handle func(f wire.Frame) (bool, error)) (string, <-chan wire.Frame) {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	frames := make(chan wire.Frame, 100)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var conns []net.Conn
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		for _, nc := range conns {
+			nc.Close()
+		}
+		mu.Unlock()
+		wg.Wait()
+	})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, nc)
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				br := bufio.NewReader(nc)
+				for {
+					f, err := wire.Read(br)
+					if err != nil {
+						return
+					}
+					frames <- f
+					if ok, err := handle(f); ok {
+						reply := wire.Frame{Type: wire.Reply, ID: f.ID}
+						if err != nil {
+							reply.Data = []byte(err.Error())
+						}
+						wire.Write(nc, reply)
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String(), frames
+}
+
+// nextFrame returns the next frame of the type read by a fake server.
+func nextFrame(t *testing.T, frames <-chan wire.Frame, typ wire.Type) wire.Frame {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f := <-frames:
+			if f.Type == typ {
+				return f
+			}
+		case <-timeout:
+			t.Fatalf("no frame of type %v read", typ)
+		}
+	}
+}
+
+func TestSubscribeCanceled(t *testing.T) {
+	// A server that never replies.
+	addr, frames := fakeServer(t, "127.0.0.1:0", func(wire.Frame) (bool, error) { return false, nil })
+	c, err := Dial[string](context.Background(), addr, pubsub.JSON)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Subscribe(ctx, "orders", 1); err != context.DeadlineExceeded {
+		t.Fatalf("Subscribe() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	sub := nextFrame(t, frames, wire.Subscribe)
+	if unsub := nextFrame(t, frames, wire.Unsubscribe); unsub.Sub != sub.Sub {
+		t.Errorf("unsubscribed from %d, want %d", unsub.Sub, sub.Sub)
+	}
+}
+
+func TestUnsubscribeCanceled(t *testing.T) {
+	// A server that never replies to Unsubscribe.
+	addr, frames := fakeServer(t, "127.0.0.1:0", func(f wire.Frame) (bool, error) { return f.Type != wire.Unsubscribe, nil })
+	c, err := Dial[string](context.Background(), addr, pubsub.JSON)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer c.Close()
+	sub, err := c.Subscribe(context.Background(), "orders", 1)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Unsubscribe(ctx, sub); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+	// Once with the context, and once again without it.
+	for range 2 {
+		if f := nextFrame(t, frames, wire.Unsubscribe); f.Sub != sub.id {
+			t.Errorf("unsubscribed from %d, want %d", f.Sub, sub.id)
+		}
+	}
+}
+
+func TestResubscribeRejected(t *testing.T) {
+	ctx := context.Background()
+	ps := startPubSub(t)
+	addr, stop := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	sub, err := c.Subscribe(ctx, "orders", 10)
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	waitSubscriptions(t, ps, 1)
+
+	stop()
+	// A server that rejects the subscription on reconnection.
+	fakeServer(t, addr, func(f wire.Frame) (bool, error) {
+		if f.Type == wire.Subscribe {
+			return true, errors.New("rejected")
+		}
+		return f.Type != wire.Ping, nil
+	})
+	select {
+	case _, ok := <-sub.Updates():
+		if ok {
+			t.Fatalf("received a message, want the subscription to be closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("subscription not closed")
+	}
+	if err := sub.Err(); err == nil || err.Error() != "rejected" {
+		t.Errorf("Err() = %v, want %q", err, "rejected")
+	}
+}
+
+func TestSetConnAfterClose(t *testing.T) {
+	ps := startPubSub(t)
+	addr, _ := serve(t, ps, "127.0.0.1:0")
+	c := dial(t, addr)
+	c.Close()
+
+	// Like a reconnection that succeeds while the client is closed.
+	cn, err := c.dial(context.Background())
+	if err != nil {
+		t.Fatalf("dial() error = %v", err)
+	}
+	if c.setConn(cn) {
+		t.Errorf("setConn() = true after Close")
+	}
+	if _, err := cn.nc.Read(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Read() error = %v, want the connection to be closed", err)
+	}
+}
@@ -0,0 +1,164 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Start offsets of a subscription, besides the offsets of the [Log].
+const (
+	// Earliest starts from the oldest message retained in the log.
+	Earliest int64 = -2
+	// Latest starts from the next published message. It is the default.
+	Latest int64 = -1
+)
+
+var errNoLog = errors.New("PubSub has no log")
+
+// WithLog makes the [PubSub] append every published message to the log, encoded with the codec,
+// so that subscriptions can replay them. See [StartAt] and [WithGroup].
+func WithLog(l *Log, c Codec) Option {
+	return func(o *options) {
+		o.log = l
+		o.codec = c
+	}
+}
+
+// StartAt makes the subscription start from the offset of the log, or from [Earliest] or [Latest].
+// An offset no longer retained starts from the earliest one.
+// The subscription receives the messages of the log it matches, then the published ones.
+// It requires a [Log].
+func StartAt(offset int64) SubscribeOption {
+	return func(o *subOptions) { o.start = offset }
+}
+
+// WithGroup makes the subscription a member of the consumer group, starting from the offset
+// committed by the group with [Subscription.Commit], or from the [StartAt] offset if there is none.
+// It requires a [Log].
+func WithGroup(group string) SubscribeOption {
+	return func(o *subOptions) { o.group = group }
+}
+
+// Commit commits the message as consumed by the consumer group of the subscription,
+// so that the next subscription of the group starts after it.
+func (s *Subscription[T]) Commit(msg Message[T]) error {
+	if s.state.group == "" {
+		return errors.New("subscription has no group")
+	}
+	return s.state.log.Commit(s.state.group, msg.Offset+1)
+}
+
+// startReplay starts replaying the log to the subscription from the start offset.
+// A replaying subscription is not in the topic trie: its replay goroutine sends the messages of the log
+// to its channel until it catches up with the log, and then adds it to the trie in the Run loop,
+// where no message can be published in the meantime.
+// It is called in the Run loop.
+func (ps *PubSub[T]) startReplay(sub Subscription[T], start int64) {
+	log := ps.o.log
+	sub.state.log = log
+	if sub.state.group != "" {
+		if offset, ok := log.Committed(sub.state.group); ok {
+			start = offset
+		}
+	}
+	earliest, latest := log.Earliest(), log.Latest()
+	switch {
+	case start == Earliest || (start >= 0 && start < earliest):
+		start = earliest
+	case start == Latest || start > latest:
+		start = latest
+	}
+	if start == latest {
+		ps.topics.insert(sub.pattern, sub)
+		return
+	}
+	sub.state.stop = make(chan struct{})
+	sub.state.replayed = make(chan struct{})
+	go ps.replay(sub, start)
+}
+
+func (ps *PubSub[T]) replay(sub Subscription[T], next int64) {
+	defer close(sub.state.replayed)
+	stop := sub.state.stop
+	pattern := strings.Split(sub.pattern, sep)
+	for {
+		for latest := ps.o.log.Latest(); next < latest; next++ {
+			// The retention may remove records while we replay them, like before we started.
+			rec, err := ps.o.log.readFrom(next)
+			if err != nil {
+				ps.endReplay(sub, stop, next, fmt.Errorf("replay: %w", err))
+				return
+			}
+			next = rec.Offset
+			if !matches(pattern, strings.Split(rec.Topic, sep)) {
+				continue
+			}
+			m := Message[T]{Topic: rec.Topic, Offset: rec.Offset}
+			if err := ps.o.codec.Unmarshal(rec.Data, &m.Value); err != nil {
+				ps.endReplay(sub, stop, next, fmt.Errorf("replay offset %d: %w", next, err))
+				return
+			}
+			if sub.state.ack != nil {
+				if !ps.replayProcess(stop, func() { m = ps.track(sub, m) }) {
+					return
+				}
+			}
+			select {
+			case sub.ch <- m:
+			case <-stop:
+				return
+			}
+		}
+		if ps.endReplay(sub, stop, next, nil) {
+			return
+		}
+	}
+}
+
+// endReplay ends the replay at the next offset if it caught up with the log, or with an error
+// that disconnects the subscription. It reports whether the replay ended.
+func (ps *PubSub[T]) endReplay(sub Subscription[T], stop <-chan struct{}, next int64, err error) bool {
+	ended := true
+	if !ps.replayProcess(stop, func() {
+		if err == nil && ps.o.log.Latest() != next {
+			ended = false // messages were published in the meantime
+			return
+		}
+		// Let unsubscribe close the channel without waiting for us.
+		sub.state.stop = nil
+		if err != nil {
+			ps.unsubscribe(sub, err)
+		} else {
+			ps.topics.insert(sub.pattern, sub)
+		}
+	}) {
+		return true
+	}
+	return ended
+}
+
+// replayProcess runs f in the Run loop and waits for it, like process,
+// for the replay goroutine. It reports false if the replay was stopped or Run returned.
+func (ps *PubSub[T]) replayProcess(stop <-chan struct{}, f func()) bool {
+	done := make(chan struct{})
+	select {
+	case ps.actch <- func() { f(); close(done) }:
+		<-done
+		return true
+	case <-stop:
+	case <-ps.closedch:
+	}
+	return false
+}
+
+// stopReplay stops the replay of the subscription, if any, before its channel is closed.
+// It is called in the Run loop.
+func (s Subscription[T]) stopReplay() {
+	if s.state.stop == nil {
+		return
+	}
+	close(s.state.stop)
+	<-s.state.replayed
+	s.state.stop = nil
+}
@@ -0,0 +1,173 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// startDurable runs a new PubSub with a log until the test ends.
+func startDurable(t *testing.T, dir string) *PubSub[string] {
+	t.Helper()
+	return start[string](t, WithLog(openLog(t, dir), JSON))
+}
+
+// next receives the next message of the subscription.
+func next[T any](t *testing.T, sub Subscription[T]) Message[T] {
+	t.Helper()
+	select {
+	case msg, ok := <-sub.Updates():
+		if !ok {
+			t.Fatalf("subscription closed: %v", sub.Err())
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message received")
+	}
+	panic("unreachable")
+}
+
+func publish(t *testing.T, ps *PubSub[string], topic string, msgs ...string) {
+	t.Helper()
+	for _, msg := range msgs {
+		if err := ps.Publish(context.Background(), topic, msg); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	ps := startDurable(t, t.TempDir())
+	publish(t, ps, "orders.created", "a")
+	publish(t, ps, "users.created", "b")
+	publish(t, ps, "orders.deleted", "c")
+
+	tests := []struct {
+		start int64
+		want  []Message[string]
+	}{
+		{Earliest, []Message[string]{{Topic: "orders.created", Offset: 0, Value: "a"}, {Topic: "orders.deleted", Offset: 2, Value: "c"}, {Topic: "orders.created", Offset: 3, Value: "d"}}},
+		{1, []Message[string]{{Topic: "orders.deleted", Offset: 2, Value: "c"}, {Topic: "orders.created", Offset: 3, Value: "d"}}},
+		{Latest, []Message[string]{{Topic: "orders.created", Offset: 3, Value: "d"}}},
+	}
+	var subs []Subscription[string]
+	for _, tt := range tests {
+		sub, err := ps.Subscribe("orders.*", 10, StartAt(tt.start))
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		subs = append(subs, sub)
+	}
+	publish(t, ps, "orders.created", "d")
+
+	for i, tt := range tests {
+		for _, want := range tt.want {
+			if got := next(t, subs[i]); got != want {
+				t.Errorf("start %d: received %v, want %v", tt.start, got, want)
+			}
+		}
+	}
+}
+
+func TestReplayRetention(t *testing.T) {
+	log := openLog(t, t.TempDir(), WithSegmentSize(1), WithMaxSize(100)) // 2 records are retained
+	ps := start[string](t, WithLog(log, JSON))
+	for i := range 10 {
+		publish(t, ps, "orders", strconv.Itoa(i))
+	}
+	sub, err := ps.Subscribe("orders", 0, StartAt(Earliest))
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	// The replay is stuck sending offset 8 while the retention removes the records after it.
+	for i := range 10 {
+		publish(t, ps, "orders", strconv.Itoa(10+i))
+	}
+
+	for _, want := range []int64{8, 18, 19} {
+		if m := next(t, sub); m.Offset != want {
+			t.Errorf("received offset %d, want %d", m.Offset, want)
+		}
+	}
+	published := make(chan error)
+	go func() { published <- ps.Publish(context.Background(), "orders", "20") }()
+	if m := next(t, sub); m.Offset != 20 {
+		t.Errorf("received offset %d after the replay, want 20", m.Offset)
+	}
+	if err := <-published; err != nil {
+		t.Errorf("Publish() error = %v", err)
+	}
+}
+
+func TestGroupCommit(t *testing.T) {
+	dir := t.TempDir()
+	ps := startDurable(t, dir)
+	publish(t, ps, "orders", "a", "b", "c")
+
+	sub, _ := ps.Subscribe("orders", 10, WithGroup("billing"), StartAt(Earliest))
+	next(t, sub)
+	if err := sub.Commit(next(t, sub)); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	ps.Unsubscribe(sub)
+
+	sub, _ = ps.Subscribe("orders", 10, WithGroup("billing"), StartAt(Earliest))
+	if got := next(t, sub); got.Value != "c" {
+		t.Errorf("received %q after the commit, want %q", got.Value, "c")
+	}
+	if err := (&Subscription[string]{state: &subState{}}).Commit(Message[string]{}); err == nil {
+		t.Errorf("Commit() error = nil without a group")
+	}
+}
+
+// TestReplayHandover checks that a replaying subscription receives every message once and in order
+// while messages are published.
+func TestReplayHandover(t *testing.T) {
+	const n = 1000
+	ps := startDurable(t, t.TempDir())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range n {
+			ps.Publish(context.Background(), "numbers", "n")
+		}
+	}()
+
+	time.Sleep(time.Millisecond)
+	sub, err := ps.Subscribe("numbers", 1, StartAt(Earliest))
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	for offset := range int64(n) {
+		if got := next(t, sub); got.Offset != offset {
+			t.Fatalf("received offset %d, want %d", got.Offset, offset)
+		}
+	}
+	<-done
+}
+
+func TestUnsubscribeWhileReplaying(t *testing.T) {
+	ps := startDurable(t, t.TempDir())
+	for range 10 {
+		publish(t, ps, "numbers", "n")
+	}
+	sub, _ := ps.Subscribe("numbers", 0, StartAt(Earliest))
+	next(t, sub)
+	ps.Unsubscribe(sub)
+	for range sub.Updates() {
+	}
+	// The PubSub is still usable.
+	publish(t, ps, "numbers", "n")
+}
+
+func TestReplayWithoutLog(t *testing.T) {
+	ps := start[string](t)
+	if _, err := ps.Subscribe("orders", 1, StartAt(Earliest)); !errors.Is(err, errNoLog) {
+		t.Errorf("Subscribe() error = %v, want %v", err, errNoLog)
+	}
+	if _, err := ps.Subscribe("orders", 1, WithGroup("g")); !errors.Is(err, errNoLog) {
+		t.Errorf("Subscribe() error = %v, want %v", err, errNoLog)
+	}
+}
@@ -0,0 +1,130 @@
+// Package wire implements the framed protocol between the pubsub server and client.
+//
+// A frame is its type, the length of its body as a uvarint, and the body:
+// the ID and subscription ID as uvarints, the topic as a uvarint length and the bytes,
+// the offset as a varint, and the data up to the end of the body.
+//
+// The client sends Subscribe, Unsubscribe and Publish requests with increasing IDs,
+// and the server answers each with a Reply with the same ID and the error text, if any, as the data.
+// The server sends the messages of a subscription as Message frames with the ID of the subscription,
+// chosen by the client. Either side sends a Ping every heartbeat interval and answers a Ping with a Pong.
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Type is the type of a frame.
+type Type uint8
+
+const (
+	Subscribe Type = iota + 1
+	Unsubscribe
+	Publish
+	Message
+	Reply
+	Ping
+	Pong
+)
+
+// MaxSize is the maximum size of a frame body.
+const MaxSize = 16 << 20
+
+// Frame is a protocol frame. The fields not used by its type are zero.
+type Frame struct {
+	Type   Type
+	ID     uint64 // of the request, or of the subscription of a Message
+	Sub    uint64 // of the subscription of a Subscribe or an Unsubscribe
+	Topic  string // or pattern
+	Offset int64
+	Data   []byte
+}
+
+var errTooLarge = errors.New("wire: frame too large")
+
+// Append appends the encoded frame to buf.
+func Append(buf []byte, f Frame) []byte {
+	var body []byte
+	body = binary.AppendUvarint(body, f.ID)
+	body = binary.AppendUvarint(body, f.Sub)
+	body = binary.AppendUvarint(body, uint64(len(f.Topic)))
+	body = append(body, f.Topic...)
+	body = binary.AppendVarint(body, f.Offset)
+	body = append(body, f.Data...)
+
+	buf = append(buf, byte(f.Type))
+	buf = binary.AppendUvarint(buf, uint64(len(body)))
+	return append(buf, body...)
+}
+
+// Write writes the frame to w.
+func Write(w io.Writer, f Frame) error {
+	buf := Append(nil, f)
+	if len(buf) > MaxSize {
+		return errTooLarge
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// Read reads a frame from r.
+func Read(r *bufio.Reader) (Frame, error) {
+	t, err := r.ReadByte()
+	if err != nil {
+		return Frame{}, err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return Frame{}, unexpectedEOF(err)
+	}
+	if n > MaxSize {
+		return Frame{}, errTooLarge
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return Frame{}, unexpectedEOF(err)
+	}
+
+	f := Frame{Type: Type(t)}
+	var ok bool
+	if f.ID, body, ok = uvarint(body); !ok {
+		return Frame{}, errCorrupted("ID")
+	}
+	if f.Sub, body, ok = uvarint(body); !ok {
+		return Frame{}, errCorrupted("subscription ID")
+	}
+	topicLen, body, ok := uvarint(body)
+	if !ok || topicLen > uint64(len(body)) {
+		return Frame{}, errCorrupted("topic")
+	}
+	f.Topic, body = string(body[:topicLen]), body[topicLen:]
+	offset, k := binary.Varint(body)
+	if k <= 0 {
+		return Frame{}, errCorrupted("offset")
+	}
+	f.Offset, f.Data = offset, body[k:]
+	return f, nil
+}
+
+func uvarint(b []byte) (uint64, []byte, bool) {
+	v, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, false
+	}
+	return v, b[n:], true
+}
+
+func errCorrupted(field string) error {
+	return fmt.Errorf("wire: corrupted %s", field)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package wire
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{Type: Subscribe, ID: 1, Sub: 7, Topic: "orders.>"},
+		{Type: Publish, ID: 2, Topic: "orders.created", Data: []byte(`{"id":1}`)},
+		{Type: Message, ID: 7, Topic: "orders.created", Offset: -1, Data: []byte("x")},
+		{Type: Reply, ID: 2, Data: []byte("invalid topic")},
+		{Type: Ping},
+	}
+	var buf bytes.Buffer
+	for _, f := range frames {
+		if err := Write(&buf, f); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	r := bufio.NewReader(&buf)
+	for _, want := range frames {
+		got, err := Read(r)
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if len(got.Data) == 0 {
+			got.Data = nil
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Read() = %+v, want %+v", got, want)
+		}
+	}
+	if _, err := Read(r); err != io.EOF {
+		t.Errorf("Read() error = %v at the end, want %v", err, io.EOF)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	b := Append(nil, Frame{Type: Publish, ID: 1, Topic: "orders", Data: []byte("data")})
+	for n := 1; n < len(b); n++ {
+		if _, err := Read(bufio.NewReader(bytes.NewReader(b[:n]))); err == nil {
+			t.Errorf("Read() of %d of %d bytes error = nil", n, len(b))
+		}
+	}
+}
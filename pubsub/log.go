@@ -0,0 +1,425 @@
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log is an append-only log of records on local disk, split into segment files.
+// Every record has an offset, which increases by one with every appended record.
+// Old segments are removed according to the retention options.
+//
+// Records are written to the OS on every append, but they are only synced to disk
+// by [Log.Sync] and [Log.Close]. A torn record at the end of the log, left by a crash, is discarded on open.
+// It is safe for concurrent use.
+type Log struct {
+	dir string
+	o   logOptions
+
+	mu      sync.Mutex
+	segs    []*segment // from the oldest, the last one is active
+	next    int64      // offset of the next record
+	offsets map[string]int64
+}
+
+// Record is a record of a [Log].
+type Record struct {
+	Offset int64
+	Time   time.Time
+	Topic  string
+	Data   []byte
+}
+
+type segment struct {
+	base int64 // offset of the first record
+	f    *os.File
+	pos  []int64 // file positions of the records
+	size int64
+	last time.Time // time of the newest record
+}
+
+// LogOption configures a [Log].
+type LogOption func(*logOptions)
+
+type logOptions struct {
+	segmentSize int64
+	maxSize     int64
+	maxAge      time.Duration
+	now         func() time.Time
+}
+
+// WithSegmentSize sets the size in bytes after which a new segment is started.
+// The default is 16 MiB.
+func WithSegmentSize(n int64) LogOption {
+	return func(o *logOptions) { o.segmentSize = n }
+}
+
+// WithMaxSize removes the oldest segments while the log is larger than n bytes.
+// The active segment is never removed.
+func WithMaxSize(n int64) LogOption {
+	return func(o *logOptions) { o.maxSize = n }
+}
+
+// WithMaxAge removes the segments with all records older than d.
+// The active segment is never removed.
+func WithMaxAge(d time.Duration) LogOption {
+	return func(o *logOptions) { o.maxAge = d }
+}
+
+// WithLogClock sets the function used to get the time of the records.
+// The default is [time.Now].
+func WithLogClock(now func() time.Time) LogOption {
+	return func(o *logOptions) { o.now = now }
+}
+
+const (
+	segmentExt  = ".log"
+	offsetsFile = "offsets.json"
+	// headerSize is the size of the checksum and the length of the record body.
+	headerSize = 8
+	// bodyHeaderSize is the size of the offset, time and topic length at the start of the body.
+	bodyHeaderSize = 8 + 8 + 2
+	// maxBodySize bounds the body of a record, so that a corrupted length is not trusted.
+	maxBodySize = 64 << 20
+)
+
+var errCorrupted = errors.New("corrupted record")
+
+// OpenLog opens the log in the directory, creating it if needed.
+func OpenLog(dir string, opts ...LogOption) (*Log, error) {
+	o := logOptions{segmentSize: 16 << 20, now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	l := &Log{dir: dir, o: o, offsets: make(map[string]int64)}
+	if err := l.load(); err != nil {
+		l.closeSegments()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) load() error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+	var bases []int64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), segmentExt)
+		if !ok {
+			continue
+		}
+		base, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	slices.Sort(bases)
+	for i, base := range bases {
+		if i > 0 && base != l.next {
+			return fmt.Errorf("segment %d does not follow offset %d", base, l.next)
+		}
+		seg, err := openSegment(l.segmentPath(base), base, i == len(bases)-1)
+		if err != nil {
+			return fmt.Errorf("segment %d: %w", base, err)
+		}
+		l.segs = append(l.segs, seg)
+		l.next = base + int64(len(seg.pos))
+	}
+	if len(l.segs) == 0 {
+		if err := l.roll(); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(l.dir, offsetsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &l.offsets)
+}
+
+// openSegment opens the segment and indexes its records.
+// A corrupted record is an error, except at the end of the last segment, where it is truncated.
+func openSegment(path string, base int64, last bool) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{base: base, f: f}
+	r := bufio.NewReader(f)
+	for {
+		rec, n, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err == nil && rec.Offset != base+int64(len(seg.pos)) {
+			err = errCorrupted
+		}
+		if err != nil {
+			if !last || !(err == errCorrupted || err == io.ErrUnexpectedEOF) {
+				f.Close()
+				return nil, err
+			}
+			if err := f.Truncate(seg.size); err != nil {
+				f.Close()
+				return nil, err
+			}
+			break
+		}
+		seg.pos = append(seg.pos, seg.size)
+		seg.size += n
+		seg.last = rec.Time
+	}
+	return seg, nil
+}
+
+// Append appends a record with the topic and data, returning its offset.
+func (l *Log) Append(topic string, data []byte) (int64, error) {
+	if len(topic) > math.MaxUint16 {
+		return 0, fmt.Errorf("topic %q too long", topic)
+	}
+	if n := bodyHeaderSize + len(topic) + len(data); n > maxBodySize {
+		return 0, fmt.Errorf("record of %d bytes too large", n)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seg := l.segs[len(l.segs)-1]
+	if seg.size >= l.o.segmentSize && len(seg.pos) > 0 {
+		if err := l.roll(); err != nil {
+			return 0, err
+		}
+		seg = l.segs[len(l.segs)-1]
+	}
+	rec := Record{Offset: l.next, Time: l.o.now(), Topic: topic, Data: data}
+	buf := appendRecord(nil, rec)
+	if _, err := seg.f.Write(buf); err != nil {
+		// Drop what may have been written, so that the record can be appended again.
+		seg.f.Truncate(seg.size)
+		return 0, err
+	}
+	seg.pos = append(seg.pos, seg.size)
+	seg.size += int64(len(buf))
+	seg.last = rec.Time
+	l.next++
+	l.retain()
+	return rec.Offset, nil
+}
+
+// Read returns the record at the offset.
+// It returns an error if the offset is not between [Log.Earliest] and [Log.Latest].
+func (l *Log) Read(offset int64) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read(offset)
+}
+
+// readFrom is like [Log.Read], but reads the earliest record if the offset is no longer retained.
+func (l *Log) readFrom(offset int64) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read(max(offset, l.earliest()))
+}
+
+func (l *Log) read(offset int64) (Record, error) {
+	i := sort.Search(len(l.segs), func(i int) bool { return l.segs[i].base > offset }) - 1
+	if i < 0 || offset >= l.next {
+		return Record{}, fmt.Errorf("offset %d out of range [%d, %d)", offset, l.earliest(), l.next)
+	}
+	seg := l.segs[i]
+	end := seg.size
+	if j := offset - seg.base + 1; j < int64(len(seg.pos)) {
+		end = seg.pos[j]
+	}
+	start := seg.pos[offset-seg.base]
+	buf := make([]byte, end-start)
+	if _, err := seg.f.ReadAt(buf, start); err != nil {
+		return Record{}, err
+	}
+	rec, _, err := readRecord(bytes.NewReader(buf))
+	return rec, err
+}
+
+// Earliest returns the offset of the oldest retained record,
+// or [Log.Latest] if there are none.
+func (l *Log) Earliest() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.earliest()
+}
+
+// Latest returns the offset of the next record to be appended.
+func (l *Log) Latest() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next
+}
+
+// Commit stores the offset of the next record to be consumed by the consumer group.
+func (l *Log) Commit(group string, offset int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.offsets[group] = offset
+	data, err := json.Marshal(l.offsets)
+	if err != nil {
+		return err
+	}
+	// Write and rename, so that a crash leaves either the old or the new offsets.
+	tmp := filepath.Join(l.dir, offsetsFile+".tmp")
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(l.dir, offsetsFile))
+}
+
+// Committed returns the offset committed by the consumer group.
+func (l *Log) Committed(group string) (offset int64, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	offset, ok = l.offsets[group]
+	return offset, ok
+}
+
+// Sync syncs the active segment to disk.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.segs[len(l.segs)-1].f.Sync()
+}
+
+// Close syncs and closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.segs[len(l.segs)-1].f.Sync()
+	return errors.Join(err, l.closeSegments())
+}
+
+func (l *Log) closeSegments() error {
+	var err error
+	for _, seg := range l.segs {
+		err = errors.Join(err, seg.f.Close())
+	}
+	return err
+}
+
+func (l *Log) earliest() int64 {
+	if seg := l.segs[0]; len(seg.pos) > 0 {
+		return seg.base
+	}
+	return l.next
+}
+
+// roll starts a new segment.
+func (l *Log) roll() error {
+	f, err := os.OpenFile(l.segmentPath(l.next), os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	l.segs = append(l.segs, &segment{base: l.next, f: f})
+	return nil
+}
+
+// retain removes the oldest segments exceeding the retention options.
+// Errors are ignored, as a segment failing to be removed is removed on a later append.
+func (l *Log) retain() {
+	var total int64
+	for _, seg := range l.segs {
+		total += seg.size
+	}
+	now := l.o.now()
+	for len(l.segs) > 1 {
+		seg := l.segs[0]
+		tooBig := l.o.maxSize > 0 && total > l.o.maxSize
+		tooOld := l.o.maxAge > 0 && now.Sub(seg.last) > l.o.maxAge
+		if !tooBig && !tooOld {
+			return
+		}
+		seg.f.Close()
+		if err := os.Remove(seg.f.Name()); err != nil {
+			return
+		}
+		total -= seg.size
+		l.segs = l.segs[1:]
+	}
+}
+
+func (l *Log) segmentPath(base int64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", base, segmentExt))
+}
+
+// appendRecord appends the encoded record to buf:
+// the CRC-32 of the body and its length, followed by the body with
+// the offset, the time in Unix nanoseconds, the topic length, the topic and the data.
+func appendRecord(buf []byte, rec Record) []byte {
+	bodyLen := bodyHeaderSize + len(rec.Topic) + len(rec.Data)
+	buf = slices.Grow(buf, headerSize+bodyLen)
+	buf = buf[:headerSize]
+	buf = binary.BigEndian.AppendUint64(buf, uint64(rec.Offset))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(rec.Time.UnixNano()))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(rec.Topic)))
+	buf = append(buf, rec.Topic...)
+	buf = append(buf, rec.Data...)
+	binary.BigEndian.PutUint32(buf[0:], crc32.ChecksumIEEE(buf[headerSize:]))
+	binary.BigEndian.PutUint32(buf[4:], uint32(bodyLen))
+	return buf
+}
+
+// readRecord reads a record, returning it with its encoded size.
+// It returns [io.EOF] at the end of r, and [io.ErrUnexpectedEOF] if the record is truncated.
+func readRecord(r io.Reader) (Record, int64, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Record{}, 0, err
+	}
+	sum := binary.BigEndian.Uint32(header[0:])
+	bodyLen := binary.BigEndian.Uint32(header[4:])
+	if bodyLen < bodyHeaderSize || bodyLen > maxBodySize {
+		return Record{}, 0, errCorrupted
+	}
+	body := make([]byte, bodyLen)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Record{}, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != sum {
+		return Record{}, 0, errCorrupted
+	}
+	topicLen := int(binary.BigEndian.Uint16(body[16:]))
+	if bodyHeaderSize+topicLen > len(body) {
+		return Record{}, 0, errCorrupted
+	}
+	rec := Record{
+		Offset: int64(binary.BigEndian.Uint64(body[0:])),
+		Time:   time.Unix(0, int64(binary.BigEndian.Uint64(body[8:]))),
+		Topic:  string(body[bodyHeaderSize : bodyHeaderSize+topicLen]),
+		Data:   body[bodyHeaderSize+topicLen:],
+	}
+	return rec, int64(headerSize + bodyLen), nil
+}
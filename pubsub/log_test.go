@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openLog(t *testing.T, dir string, opts ...LogOption) *Log {
+	t.Helper()
+	l, err := OpenLog(dir, opts...)
+	if err != nil {
+		t.Fatalf("OpenLog() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return l
+}
+
+func appendN(t *testing.T, l *Log, n int) {
+	t.Helper()
+	for range n {
+		offset := l.Latest()
+		got, err := l.Append("topic", fmt.Appendf(nil, "record %d", offset))
+		if err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+		if got != offset {
+			t.Fatalf("Append() = %d, want %d", got, offset)
+		}
+	}
+}
+
+func checkRecords(t *testing.T, l *Log, from, to int64) {
+	t.Helper()
+	if l.Earliest() != from || l.Latest() != to {
+		t.Fatalf("offsets [%d, %d), want [%d, %d)", l.Earliest(), l.Latest(), from, to)
+	}
+	for offset := from; offset < to; offset++ {
+		rec, err := l.Read(offset)
+		if err != nil {
+			t.Fatalf("Read(%d) error = %v", offset, err)
+		}
+		if want := fmt.Sprintf("record %d", offset); rec.Offset != offset || rec.Topic != "topic" || string(rec.Data) != want {
+			t.Errorf("Read(%d) = %d %q %q, want %d %q %q", offset, rec.Offset, rec.Topic, rec.Data, offset, "topic", want)
+		}
+	}
+	if _, err := l.Read(to); err == nil {
+		t.Errorf("Read(%d) error = nil past the end", to)
+	}
+}
+
+func TestLogReopen(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir, WithSegmentSize(100))
+	appendN(t, l, 10)
+	checkRecords(t, l, 0, 10)
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	l = openLog(t, dir, WithSegmentSize(100))
+	checkRecords(t, l, 0, 10)
+	appendN(t, l, 5)
+	checkRecords(t, l, 0, 15)
+}
+
+func TestLogTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	appendN(t, l, 3)
+	l.Close()
+
+	// Cut the last record in the middle, like a crash during a write.
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt))
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	l = openLog(t, dir)
+	checkRecords(t, l, 0, 2)
+	appendN(t, l, 1)
+	checkRecords(t, l, 0, 3)
+}
+
+func TestLogCorruptedLength(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	appendN(t, l, 3)
+	l.Close()
+
+	// Set the body length of the last record to 4 GiB; it must not be allocated.
+	path := filepath.Join(dir, fmt.Sprintf("%020d%s", 0, segmentExt))
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 2*39+4); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	l = openLog(t, dir)
+	checkRecords(t, l, 0, 2)
+}
+
+func TestLogMaxSize(t *testing.T) {
+	l := openLog(t, t.TempDir(), WithSegmentSize(1), WithMaxSize(100)) // a record per segment
+	appendN(t, l, 10)
+	// Records are 8 + 18 + len("topic") + len("record N") = 39 bytes, so 2 segments fit.
+	checkRecords(t, l, 8, 10)
+}
+
+func TestLogMaxAge(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := openLog(t, t.TempDir(), WithSegmentSize(1), WithMaxAge(90*time.Second), WithLogClock(clock.Now))
+	for range 3 {
+		appendN(t, l, 1)
+		clock.Advance(time.Minute)
+	}
+	// The record at 0s is older than 90s at 120s, the one at 60s is not.
+	checkRecords(t, l, 1, 3)
+}
+
+func TestLogCommit(t *testing.T) {
+	dir := t.TempDir()
+	l := openLog(t, dir)
+	if _, ok := l.Committed("g"); ok {
+		t.Errorf("Committed() ok = true before a commit")
+	}
+	if err := l.Commit("g", 42); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	l.Close()
+
+	l = openLog(t, dir)
+	if offset, ok := l.Committed("g"); !ok || offset != 42 {
+		t.Errorf("Committed() = %d, %t, want 42, true", offset, ok)
+	}
+}
@@ -0,0 +1,95 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrSlowSubscriber is the error of a subscription disconnected by the [Disconnect] policy.
+var ErrSlowSubscriber = errors.New("slow subscriber disconnected")
+
+// Policy is what happens to a message published to a subscription with a full buffer.
+type Policy int
+
+const (
+	// Block waits for room in the buffer, stalling the delivery to the other subscriptions,
+	// until the context of Publish is canceled.
+	Block Policy = iota
+	// DropNewest drops the published message.
+	DropNewest
+	// DropOldest drops the oldest buffered message to make room for the published one.
+	DropOldest
+	// Disconnect drops the published message and unsubscribes the subscription with [ErrSlowSubscriber].
+	Disconnect
+)
+
+// SubscribeOption configures a [Subscription].
+type SubscribeOption func(*subOptions)
+
+type subOptions struct {
+	policy  Policy
+	start   int64
+	group   string
+	ack     *AckConfig
+	queue   string
+	balance Balance
+}
+
+// WithPolicy sets the policy of the subscription for a full buffer.
+// The default is [Block].
+func WithPolicy(p Policy) SubscribeOption {
+	return func(o *subOptions) { o.policy = p }
+}
+
+// subState is the state of a subscription shared by its copies.
+type subState struct {
+	policy  Policy
+	group   string
+	log     *Log
+	ack     *AckConfig // nil if not in the acknowledgement mode
+	queue   string     // empty if not in a queue group
+	balance Balance
+	dropped atomic.Uint64
+	err     error // set before the channel is closed
+
+	// Set while the subscription replays the log, see startReplay.
+	stop     chan struct{} // closed to stop the replay
+	replayed chan struct{} // closed when the replay stops
+}
+
+// deliver sends the message to the subscription according to its policy.
+// Unless wait is set, the [Block] policy drops the message instead of waiting.
+// It reports false if the subscription must be disconnected.
+// It is called from the Run loop, the only sender to the channel.
+func (s Subscription[T]) deliver(ctx context.Context, msg Message[T], wait bool) (ok bool, err error) {
+	if s.state.policy == Block && wait {
+		select {
+		case s.ch <- msg:
+			return true, nil
+		case <-ctx.Done():
+			return true, ctx.Err()
+		}
+	}
+
+	select {
+	case s.ch <- msg:
+		return true, nil
+	default:
+	}
+	s.state.dropped.Add(1)
+	switch s.state.policy {
+	case DropOldest:
+		select {
+		case <-s.ch:
+		default: // the subscriber emptied the buffer in the meantime
+		}
+		select {
+		case s.ch <- msg:
+		default: // an unbuffered subscription with no receiver ready
+		}
+	case Disconnect:
+		return false, nil
+	}
+	return true, nil
+}
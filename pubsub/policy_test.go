@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		policy      Policy
+		want        []int
+		wantDropped uint64
+		wantErr     error
+	}{
+		{DropNewest, []int{1, 2}, 3, nil},
+		{DropOldest, []int{4, 5}, 3, nil},
+		{Disconnect, []int{1, 2}, 1, ErrSlowSubscriber},
+	}
+	for _, tt := range tests {
+		ps := start[int](t)
+		sub, err := ps.Subscribe("numbers", 2, WithPolicy(tt.policy))
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		for i := 1; i <= 5; i++ {
+			if err := ps.Publish(context.Background(), "numbers", i); err != nil {
+				t.Fatalf("policy %d: Publish(%d) error = %v", tt.policy, i, err)
+			}
+		}
+		if got := received(sub); !slices.Equal(got, tt.want) {
+			t.Errorf("policy %d: received %v, want %v", tt.policy, got, tt.want)
+		}
+		if got := sub.Dropped(); got != tt.wantDropped {
+			t.Errorf("policy %d: Dropped() = %d, want %d", tt.policy, got, tt.wantDropped)
+		}
+		if tt.wantErr != nil {
+			if _, ok := <-sub.Updates(); ok {
+				t.Fatalf("policy %d: Updates() is not closed", tt.policy)
+			}
+			if err := sub.Err(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("policy %d: Err() = %v, want %v", tt.policy, err, tt.wantErr)
+			}
+		}
+	}
+}
+
+func TestSlowSubscriberDoesNotStall(t *testing.T) {
+	ps := start[int](t)
+	stuck, _ := ps.Subscribe(">", 1, WithPolicy(DropNewest))
+	fast, _ := ps.Subscribe(">", 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := range 10 {
+		if err := ps.Publish(ctx, "numbers", i); err != nil {
+			t.Fatalf("Publish(%d) error = %v", i, err)
+		}
+	}
+	if got := len(received(fast)); got != 10 {
+		t.Errorf("the fast subscription received %d messages, want 10", got)
+	}
+	if got := stuck.Dropped(); got != 9 {
+		t.Errorf("Dropped() = %d, want 9", got)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	ps := start[int](t)
+	sub, _ := ps.Subscribe(">", 1, WithPolicy(Block))
+	if err := ps.Publish(context.Background(), "numbers", 1); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ps.Publish(ctx, "numbers", 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Publish() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if sub.Dropped() != 0 {
+		t.Errorf("Dropped() = %d, want 0", sub.Dropped())
+	}
+}
@@ -1,4 +1,5 @@
-// Package pubsub implements a Pub/Sub.
+// Package pubsub implements a Pub/Sub with topics and wildcard subscriptions,
+// optionally backed by a durable log that subscriptions can replay.
 package pubsub
 
 import (
@@ -7,28 +8,81 @@ import (
 	"fmt"
 	"iter"
 	"slices"
+	"strings"
 )
 
 var errPubSubClosed = errors.New("PubSub closed")
 
+// Message is a message delivered to a [Subscription].
+type Message[T any] struct {
+	Topic string
+	// Offset is the position of the message in the [Log],
+	// or in the sequence of published messages if there is no log.
+	Offset int64
+	// ReplyTo is the inbox to reply to with [PubSub.Reply] if the message is a [PubSub.Request].
+	ReplyTo string
+	// Attempt is the delivery attempt of the message, from 1, in the acknowledgement mode. See [WithAck].
+	Attempt int
+	Value   T
+
+	delivery *delivery // nil if the subscription is not in the acknowledgement mode
+}
+
 // Subscription is a [PubSub] subscription.
 type Subscription[T any] struct {
-	ch chan T
+	ch      chan Message[T]
+	pattern string
+	state   *subState
 }
 
+// Pattern returns the topic pattern of the subscription.
+func (s *Subscription[T]) Pattern() string { return s.pattern }
+
 // Updates returns a channel to receive messages from [PubSub].
-func (s *Subscription[T]) Updates() <-chan T { return s.ch }
+func (s *Subscription[T]) Updates() <-chan Message[T] { return s.ch }
+
+// Dropped returns the number of messages dropped because the buffer was full.
+func (s *Subscription[T]) Dropped() uint64 { return s.state.dropped.Load() }
+
+// Err returns the reason the subscription was disconnected, such as [ErrSlowSubscriber],
+// once the channel returned by Updates is closed. It returns nil if it was closed by Unsubscribe
+// or by the end of Run.
+func (s *Subscription[T]) Err() error { return s.state.err }
 
 // PubSub is a Pub/Sub system.
+// Messages are published to topics, and delivered to the subscriptions with matching patterns.
 type PubSub[T any] struct {
-	subs     []Subscription[T]
-	actch    chan func()
-	closedch chan struct{}
+	o         options
+	subs      []Subscription[T]
+	topics    trie[T]
+	next      int64 // offset of the next message if there is no log
+	pending   map[uint64]*pending[T]
+	nextID    uint64                     // ID of the last pending message
+	inboxes   map[string]chan Message[T] // of the pending requests, by topic
+	nextInbox uint64                     // ID of the last request inbox
+	actch     chan func()
+	closedch  chan struct{}
+}
+
+// Option configures a [PubSub].
+type Option func(*options)
+
+type options struct {
+	log   *Log
+	codec Codec
+	clock Clock
 }
 
 // NewPubSub creates and returns a new [PubSub] instance.
-func NewPubSub[T any]() *PubSub[T] {
+func NewPubSub[T any](opts ...Option) *PubSub[T] {
+	o := options{clock: realClock{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
 	return &PubSub[T]{
+		o:        o,
+		pending:  make(map[uint64]*pending[T]),
+		inboxes:  make(map[string]chan Message[T]),
 		actch:    make(chan func()),
 		closedch: make(chan struct{}),
 	}
@@ -46,49 +100,112 @@ func (ps *PubSub[T]) Run(ctx context.Context) {
 			f()
 		case <-ctx.Done():
 			for _, sub := range ps.subs {
+				sub.stopReplay()
 				close(sub.ch)
 			}
 			clear(ps.subs)
+			ps.topics = trie[T]{}
+			for _, p := range ps.pending {
+				p.timer.Stop()
+			}
+			clear(ps.pending)
+			for _, inbox := range ps.inboxes {
+				close(inbox)
+			}
+			clear(ps.inboxes)
 			return
 		}
 	}
 }
 
-// Subscribe creates and returns a new subscription with the specified buffer size.
-func (ps *PubSub[T]) Subscribe(bufSize int) (Subscription[T], error) {
-	sub := Subscription[T]{ch: make(chan T, bufSize)}
+// Subscribe creates and returns a new subscription to the topics matching the pattern
+// with the specified buffer size.
+func (ps *PubSub[T]) Subscribe(pattern string, bufSize int, opts ...SubscribeOption) (Subscription[T], error) {
+	if err := checkPattern(pattern); err != nil {
+		return Subscription[T]{}, err
+	}
+	o := subOptions{start: Latest}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if ps.o.log == nil && (o.start != Latest || o.group != "") {
+		return Subscription[T]{}, errNoLog
+	}
+	if o.ack != nil {
+		if err := checkAck(o.ack); err != nil {
+			return Subscription[T]{}, err
+		}
+	}
+	if o.queue != "" && (o.start != Latest || o.group != "") {
+		return Subscription[T]{}, errors.New("queue group cannot replay the log")
+	}
+	if o.queue != "" && o.balance == LeastLoaded && bufSize == 0 {
+		return Subscription[T]{}, errors.New("LeastLoaded queue group member must be buffered")
+	}
+	sub := Subscription[T]{
+		ch:      make(chan Message[T], bufSize),
+		pattern: pattern,
+		state:   &subState{policy: o.policy, group: o.group, ack: o.ack, queue: o.queue, balance: o.balance},
+	}
+	errch := make(chan error, 1)
 	if err := ps.process(func() {
+		if sub.state.queue != "" {
+			if err := ps.checkQueue(sub); err != nil {
+				errch <- err
+				return
+			}
+		}
 		ps.subs = append(ps.subs, sub)
+		if ps.o.log == nil {
+			ps.topics.insert(pattern, sub)
+		} else {
+			ps.startReplay(sub, o.start)
+		}
+		errch <- nil
 	}); err != nil {
 		return Subscription[T]{}, err
 	}
+	if err := <-errch; err != nil {
+		return Subscription[T]{}, err
+	}
 	return sub, nil
 }
 
 // Unsubscribe removes the given subscription from the [PubSub].
 func (ps *PubSub[T]) Unsubscribe(sub Subscription[T]) {
-	_ = ps.process(func() {
-		// Can be optimized by swapping the element to be deleted
-		// with the last element, as we don't need to preserve the order.
-		ps.subs = slices.DeleteFunc(ps.subs, func(s Subscription[T]) bool { return s == sub })
-		close(sub.ch)
-	})
+	_ = ps.process(func() { ps.unsubscribe(sub, nil) })
 }
 
-// Publish publishes the given message to all active subscriptions.
-func (ps *PubSub[T]) Publish(ctx context.Context, msg T) error {
+// unsubscribe removes the subscription and closes it with the error.
+func (ps *PubSub[T]) unsubscribe(sub Subscription[T], err error) {
+	n := len(ps.subs)
+	// Can be optimized by swapping the element to be deleted
+	// with the last element, as we don't need to preserve the order.
+	ps.subs = slices.DeleteFunc(ps.subs, func(s Subscription[T]) bool { return s == sub })
+	if len(ps.subs) == n {
+		return // not subscribed
+	}
+	sub.stopReplay()
+	ps.untrack(sub)
+	ps.topics.remove(strings.Split(sub.pattern, sep), sub)
+	sub.state.err = err
+	close(sub.ch)
+}
+
+// Publish publishes the given message to the topic, delivering it to all active subscriptions
+// with a matching pattern. A subscription with a full buffer handles the message according to its [Policy].
+// With a [Log], the message is appended to it first.
+func (ps *PubSub[T]) Publish(ctx context.Context, topic string, msg T) error {
+	if err := checkTopic(topic); err != nil {
+		return err
+	}
+	data, err := ps.encode(msg)
+	if err != nil {
+		return err
+	}
 	ch := make(chan error)
 	if err := ps.process(func() {
-		var err error
-	loop:
-		for _, sub := range ps.subs {
-			select {
-			case sub.ch <- msg:
-			case <-ctx.Done():
-				err = errors.Join(err, fmt.Errorf("message undelivered: %w", ctx.Err()))
-				break loop
-			}
-		}
+		_, err := ps.publish(ctx, Message[T]{Topic: topic, Value: msg}, data, true)
 		ch <- err
 	}); err != nil {
 		return err
@@ -96,6 +213,59 @@ func (ps *PubSub[T]) Publish(ctx context.Context, msg T) error {
 	return <-ch
 }
 
+// encode encodes the message for the log, if any.
+func (ps *PubSub[T]) encode(msg T) ([]byte, error) {
+	if ps.o.log == nil {
+		return nil, nil
+	}
+	data, err := ps.o.codec.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("message not encoded: %w", err)
+	}
+	return data, nil
+}
+
+// publish appends the encoded message to the log, if any, and delivers it,
+// returning the number of subscriptions it matched.
+// Unless wait is set, the subscriptions with the [Block] policy drop the message if their buffer is full.
+// It is called in the Run loop.
+func (ps *PubSub[T]) publish(ctx context.Context, m Message[T], data []byte, wait bool) (int, error) {
+	m.Offset = ps.next
+	if ps.o.log != nil {
+		offset, err := ps.o.log.Append(m.Topic, data)
+		if err != nil {
+			return 0, fmt.Errorf("message not appended: %w", err)
+		}
+		m.Offset = offset
+	} else {
+		ps.next++
+	}
+
+	var n int
+	var err error
+	var slow []Subscription[T]
+	ps.topics.match(strings.Split(m.Topic, sep), func(sub Subscription[T]) bool {
+		n++
+		m := m
+		if sub.state.ack != nil {
+			m = ps.track(sub, m)
+		}
+		ok, ctxErr := sub.deliver(ctx, m, wait)
+		if ctxErr != nil {
+			err = errors.Join(err, fmt.Errorf("message undelivered: %w", ctxErr))
+			return false
+		}
+		if !ok {
+			slow = append(slow, sub)
+		}
+		return true
+	})
+	for _, sub := range slow {
+		ps.unsubscribe(sub, ErrSlowSubscriber)
+	}
+	return n, err
+}
+
 // Subscriptions returns an iterator over the current subscriptions.
 func (ps *PubSub[T]) Subscriptions() iter.Seq[Subscription[T]] {
 	// Make a snapshot.
@@ -0,0 +1,243 @@
+package pubsub
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+// start runs a new PubSub until the test ends.
+func start[T any](t *testing.T, opts ...Option) *PubSub[T] {
+	t.Helper()
+	ps := NewPubSub[T](opts...)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ps.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return ps
+}
+
+// barrier waits for the functions already sent to the Run loop to be done.
+func barrier[T any](ps *PubSub[T]) {
+	for range ps.Subscriptions() {
+	}
+}
+
+// received returns the messages buffered in the subscription, stopping when it is closed.
+func received[T any](sub Subscription[T]) []T {
+	var msgs []T
+	for {
+		select {
+		case msg, ok := <-sub.Updates():
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg.Value)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"orders", "orders", true},
+		{"orders", "orders.created", false},
+		{"orders.created", "orders.created", true},
+		{"orders.created", "orders.deleted", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "orders", false},
+		{"orders.*", "orders.eu.created", false},
+		{"orders.*.created", "orders.eu.created", true},
+		{"*.*.created", "orders.eu.created", true},
+		{"orders.>", "orders.created", true},
+		{"orders.>", "orders.eu.created", true},
+		{"orders.>", "orders", false},
+		{">", "orders.eu.created", true},
+		{"*.>", "orders", false},
+		{"*", "orders", true},
+	}
+	for _, tt := range tests {
+		ps := start[string](t)
+		sub, err := ps.Subscribe(tt.pattern, 1)
+		if err != nil {
+			t.Fatalf("Subscribe(%q) error = %v", tt.pattern, err)
+		}
+		if err := ps.Publish(context.Background(), tt.topic, "msg"); err != nil {
+			t.Fatalf("Publish(%q) error = %v", tt.topic, err)
+		}
+		if got := len(received(sub)) == 1; got != tt.want {
+			t.Errorf("pattern %q received a message to %q: %t, want %t", tt.pattern, tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestPublishToMatchingSubscriptions(t *testing.T) {
+	ps := start[string](t)
+	var subs []Subscription[string]
+	for _, pattern := range []string{"orders.created", "orders.*", "orders.>", "users.>", "orders.*"} {
+		sub, err := ps.Subscribe(pattern, 10)
+		if err != nil {
+			t.Fatalf("Subscribe(%q) error = %v", pattern, err)
+		}
+		subs = append(subs, sub)
+	}
+	for _, topic := range []string{"orders.created", "orders.eu.created", "users.created", "payments"} {
+		if err := ps.Publish(context.Background(), topic, topic); err != nil {
+			t.Fatalf("Publish(%q) error = %v", topic, err)
+		}
+	}
+
+	want := [][]string{
+		{"orders.created"},
+		{"orders.created"},
+		{"orders.created", "orders.eu.created"},
+		{"users.created"},
+		{"orders.created"},
+	}
+	for i, sub := range subs {
+		if got := received(sub); !slices.Equal(got, want[i]) {
+			t.Errorf("subscription %d (%q) received %q, want %q", i, sub.Pattern(), got, want[i])
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	ps := start[string](t)
+	a, _ := ps.Subscribe("orders.*", 10)
+	b, _ := ps.Subscribe("orders.*", 10)
+	ps.Unsubscribe(a)
+	ps.Unsubscribe(a) // unsubscribing twice does nothing
+
+	if _, ok := <-a.Updates(); ok {
+		t.Errorf("Updates() of an unsubscribed subscription is not closed")
+	}
+	if err := ps.Publish(context.Background(), "orders.created", "msg"); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if got := received(b); len(got) != 1 {
+		t.Errorf("received %q, want one message", got)
+	}
+
+	ps.Unsubscribe(b)
+	var n int
+	for range ps.Subscriptions() {
+		n++
+	}
+	if n != 0 {
+		t.Errorf("%d subscriptions left, want 0", n)
+	}
+	done := make(chan int)
+	ps.process(func() { done <- len(ps.topics.children) })
+	if n := <-done; n != 0 {
+		t.Errorf("the topic trie has %d children left, want 0", n)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	ps := start[string](t)
+	for _, pattern := range []string{"", ".", "orders.", "orders..created", "orders.>.created"} {
+		if _, err := ps.Subscribe(pattern, 1); err == nil {
+			t.Errorf("Subscribe(%q) error = nil, want an invalid pattern", pattern)
+		}
+	}
+	for _, topic := range []string{"", "orders.", "orders.*", "orders.>"} {
+		if err := ps.Publish(context.Background(), topic, "msg"); err == nil || !strings.Contains(err.Error(), "invalid topic") {
+			t.Errorf("Publish(%q) error = %v, want an invalid topic", topic, err)
+		}
+	}
+}
+
+func TestClosed(t *testing.T) {
+	ps := NewPubSub[string]()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ps.Run(ctx)
+		close(done)
+	}()
+	sub, _ := ps.Subscribe(">", 1)
+	cancel()
+	<-done
+
+	if _, ok := <-sub.Updates(); ok {
+		t.Errorf("Updates() is not closed after Run returned")
+	}
+	if err := ps.Publish(context.Background(), "orders", "msg"); err == nil {
+		t.Errorf("Publish() error = nil after Run returned")
+	}
+}
+
+// fakeClock is a [Clock] that runs the functions scheduled with AfterFunc when the time is advanced.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	c       *fakeClock
+	at      time.Time
+	f       func()
+	stopped bool
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{c: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance advances the time, running the functions of the timers that expire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var expired []*fakeTimer
+	c.timers = slices.DeleteFunc(c.timers, func(t *fakeTimer) bool {
+		if t.stopped || t.at.After(c.now) {
+			return t.stopped
+		}
+		expired = append(expired, t)
+		return true
+	})
+	c.mu.Unlock()
+	for _, t := range expired {
+		t.f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.c.mu.Lock()
+	defer t.c.mu.Unlock()
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
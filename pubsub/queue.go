@@ -0,0 +1,149 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Balance is how a queue group picks the member that receives a message.
+type Balance int
+
+const (
+	// RoundRobin picks the members in turn.
+	RoundRobin Balance = iota
+	// LeastLoaded picks the member with the fewest buffered messages, or the first one of them.
+	// The members must be buffered, since an unbuffered one never has buffered messages.
+	LeastLoaded
+)
+
+// ErrNoResponders is returned by [PubSub.Request] when no subscription matches the topic.
+var ErrNoResponders = errors.New("no responders")
+
+// inboxPrefix is the prefix of the topics of the inboxes of [PubSub.Request].
+const inboxPrefix = "_INBOX."
+
+// WithQueue makes the subscription a member of the queue group with the name.
+// A message is delivered to a single member of every group of subscriptions with the same pattern,
+// picked according to the balance. All members must use the same balance.
+// It cannot be combined with [StartAt] or [WithGroup].
+func WithQueue(name string, b Balance) SubscribeOption {
+	return func(o *subOptions) {
+		o.queue = name
+		o.balance = b
+	}
+}
+
+// queue is a queue group.
+type queue[T any] struct {
+	balance Balance
+	members []Subscription[T]
+	next    int // next member for RoundRobin
+}
+
+func (q *queue[T]) pick() Subscription[T] {
+	if q.balance == LeastLoaded {
+		best := q.members[0]
+		for _, m := range q.members[1:] {
+			if len(m.ch) < len(best.ch) {
+				best = m
+			}
+		}
+		return best
+	}
+	q.next %= len(q.members)
+	m := q.members[q.next]
+	q.next++
+	return m
+}
+
+// checkQueue checks that the subscription can join its queue group.
+// It is called in the Run loop.
+func (ps *PubSub[T]) checkQueue(sub Subscription[T]) error {
+	n := ps.topics.find(sub.pattern)
+	if n == nil {
+		return nil
+	}
+	if q, ok := n.queues[sub.state.queue]; ok && q.balance != sub.state.balance {
+		return fmt.Errorf("queue group %q has another balance", sub.state.queue)
+	}
+	return nil
+}
+
+// Request publishes the message to the topic with an inbox to reply to, see [PubSub.Reply],
+// and waits for the first reply. It returns [ErrNoResponders] if no subscription matches the topic.
+func (ps *PubSub[T]) Request(ctx context.Context, topic string, msg T) (Message[T], error) {
+	if err := checkTopic(topic); err != nil {
+		return Message[T]{}, err
+	}
+	data, err := ps.encode(msg)
+	if err != nil {
+		return Message[T]{}, err
+	}
+	type result struct {
+		name  string
+		inbox chan Message[T]
+		err   error
+	}
+	ch := make(chan result)
+	if err := ps.process(func() {
+		ps.nextInbox++
+		name := inboxPrefix + strconv.FormatUint(ps.nextInbox, 10)
+		inbox := make(chan Message[T], 1) // later replies are dropped
+		ps.inboxes[name] = inbox
+
+		n, err := ps.publish(ctx, Message[T]{Topic: topic, Value: msg, ReplyTo: name}, data, true)
+		if err == nil && n == 0 {
+			err = ErrNoResponders
+		}
+		ch <- result{name, inbox, err}
+	}); err != nil {
+		return Message[T]{}, err
+	}
+	res := <-ch
+	defer ps.process(func() { delete(ps.inboxes, res.name) })
+	if res.err != nil {
+		return Message[T]{}, res.err
+	}
+
+	select {
+	case reply, ok := <-res.inbox:
+		if !ok {
+			return Message[T]{}, errPubSubClosed
+		}
+		return reply, nil
+	case <-ctx.Done():
+		return Message[T]{}, ctx.Err()
+	}
+}
+
+// Reply sends the message to the inbox of the request. It does not wait for the requester:
+// the reply is dropped if the request already has one or is no longer waiting.
+// It only waits for the [PubSub] to be free, such as from a delivery with the [Block] policy,
+// until the context is canceled.
+// Replies go straight to the requester, so they are neither appended to the [Log]
+// nor delivered to the subscriptions.
+func (ps *PubSub[T]) Reply(ctx context.Context, req Message[T], msg T) error {
+	if req.ReplyTo == "" {
+		return errors.New("message is not a request")
+	}
+	f := func() {
+		inbox, ok := ps.inboxes[req.ReplyTo]
+		if !ok {
+			return
+		}
+		select {
+		case inbox <- Message[T]{Topic: req.ReplyTo, Value: msg}:
+		default:
+		}
+	}
+	select {
+	case ps.actch <- f:
+		return nil
+	case <-ps.closedch:
+		return errPubSubClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
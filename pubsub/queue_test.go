@@ -0,0 +1,202 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueRoundRobin(t *testing.T) {
+	ps := start[string](t)
+	var members []Subscription[string]
+	for range 3 {
+		sub, err := ps.Subscribe("jobs", 10, WithQueue("workers", RoundRobin))
+		if err != nil {
+			t.Fatalf("Subscribe() error = %v", err)
+		}
+		members = append(members, sub)
+	}
+	all, _ := ps.Subscribe("jobs", 10)
+	for i := range 6 {
+		publish(t, ps, "jobs", strconv.Itoa(i))
+	}
+
+	if got := received(all); len(got) != 6 {
+		t.Errorf("the broadcast subscription received %q, want 6 messages", got)
+	}
+	for i, sub := range members {
+		want := []string{strconv.Itoa(i), strconv.Itoa(i + 3)}
+		if got := received(sub); !slices.Equal(got, want) {
+			t.Errorf("member %d received %q, want %q", i, got, want)
+		}
+	}
+
+	ps.Unsubscribe(members[0])
+	publish(t, ps, "jobs", "a", "b")
+	if got := len(received(members[1])) + len(received(members[2])); got != 2 {
+		t.Errorf("the remaining members received %d messages, want 2", got)
+	}
+}
+
+func TestQueueLeastLoaded(t *testing.T) {
+	ps := start[string](t)
+	a, _ := ps.Subscribe("jobs", 10, WithQueue("workers", LeastLoaded))
+	b, _ := ps.Subscribe("jobs", 10, WithQueue("workers", LeastLoaded))
+	publish(t, ps, "jobs", "1")
+	<-a.Updates() // a is idle again
+	publish(t, ps, "jobs", "2", "3", "4")
+
+	// 2 goes to a, the first of the idle members, 3 to b, and 4 to a again.
+	if got, want := received(a), []string{"2", "4"}; !slices.Equal(got, want) {
+		t.Errorf("a received %q, want %q", got, want)
+	}
+	if got, want := received(b), []string{"3"}; !slices.Equal(got, want) {
+		t.Errorf("b received %q, want %q", got, want)
+	}
+}
+
+func TestQueueErrors(t *testing.T) {
+	ps := start[string](t, WithLog(openLog(t, t.TempDir()), JSON))
+	if _, err := ps.Subscribe("jobs", 1, WithQueue("workers", RoundRobin)); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	if _, err := ps.Subscribe("jobs", 1, WithQueue("workers", LeastLoaded)); err == nil {
+		t.Errorf("Subscribe() with another balance error = nil")
+	}
+	if _, err := ps.Subscribe("jobs", 1, WithQueue("others", RoundRobin), StartAt(Earliest)); err == nil {
+		t.Errorf("Subscribe() of a queue group replaying the log error = nil")
+	}
+}
+
+func TestRequest(t *testing.T) {
+	ctx := context.Background()
+	ps := start[string](t)
+	for range 2 {
+		sub, _ := ps.Subscribe("echo", 10, WithQueue("echoers", RoundRobin))
+		go func() {
+			for m := range sub.Updates() {
+				ps.Reply(ctx, m, "re: "+m.Value)
+			}
+		}()
+	}
+
+	for _, msg := range []string{"a", "b", "c"} {
+		reply, err := ps.Request(ctx, "echo", msg)
+		if err != nil {
+			t.Fatalf("Request(%q) error = %v", msg, err)
+		}
+		if want := "re: " + msg; reply.Value != want {
+			t.Errorf("Request(%q) = %q, want %q", msg, reply.Value, want)
+		}
+	}
+
+	if _, err := ps.Request(ctx, "nobody", "a"); !errors.Is(err, ErrNoResponders) {
+		t.Errorf("Request() error = %v, want %v", err, ErrNoResponders)
+	}
+
+	silent, _ := ps.Subscribe("silent", 10)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := ps.Request(ctx, "silent", "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Request() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if err := ps.Reply(context.Background(), next(t, silent), "late"); err != nil {
+		t.Errorf("Reply() to a gone inbox error = %v", err)
+	}
+	if err := ps.Reply(context.Background(), Message[string]{Topic: "silent"}, "a"); err == nil {
+		t.Errorf("Reply() to a message that is not a request error = nil")
+	}
+
+	// The inboxes are removed.
+	if n := inboxes(ps); n != 0 {
+		t.Errorf("%d inboxes left", n)
+	}
+}
+
+func inboxes[T any](ps *PubSub[T]) int {
+	ch := make(chan int, 1)
+	ps.process(func() { ch <- len(ps.inboxes) })
+	return <-ch
+}
+
+func TestRequestBypassesLog(t *testing.T) {
+	ctx := context.Background()
+	log := openLog(t, t.TempDir())
+	ps := start[string](t, WithLog(log, JSON))
+	all, _ := ps.Subscribe(">", 10)
+	echo, _ := ps.Subscribe("echo", 10)
+
+	replied := make(chan error)
+	go func() {
+		m := <-echo.Updates()
+		for sub := range ps.Subscriptions() {
+			if strings.HasPrefix(sub.Pattern(), inboxPrefix) {
+				t.Errorf("Subscriptions() has the inbox %q", sub.Pattern())
+			}
+		}
+		replied <- ps.Reply(ctx, m, "re: "+m.Value)
+	}()
+	reply, err := ps.Request(ctx, "echo", "a")
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if err := <-replied; err != nil {
+		t.Fatalf("Reply() error = %v", err)
+	}
+	if reply.Value != "re: a" {
+		t.Errorf("Request() = %q, want %q", reply.Value, "re: a")
+	}
+
+	if log.Latest() != 1 {
+		t.Errorf("Latest() = %d, want only the request in the log", log.Latest())
+	}
+	if got := received(all); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("the subscription to > received %q, want only the request", got)
+	}
+}
+
+func TestReplyCanceled(t *testing.T) {
+	ps := start[string](t)
+	full, _ := ps.Subscribe("full", 0) // never read, so a publication to it blocks the Run loop
+	published := make(chan error)
+	go func() { published <- ps.Publish(context.Background(), "full", "a") }()
+
+	// A reply to a gone request does nothing once the Run loop takes it, so retry until it is busy.
+	req := Message[string]{ReplyTo: inboxPrefix + "gone"}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := ps.Reply(ctx, req, "re: a")
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Reply() error = %v", err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Reply() does not honour the context with the Run loop busy")
+		}
+	}
+
+	<-full.Updates()
+	if err := <-published; err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := ps.Reply(context.Background(), req, "re: a"); err != nil {
+		t.Errorf("Reply() error = %v", err)
+	}
+}
+
+func TestLeastLoadedUnbuffered(t *testing.T) {
+	ps := start[string](t)
+	if _, err := ps.Subscribe("jobs", 0, WithQueue("workers", LeastLoaded)); err == nil {
+		t.Errorf("Subscribe() of an unbuffered LeastLoaded member error = nil")
+	}
+	if _, err := ps.Subscribe("jobs", 0, WithQueue("workers", RoundRobin)); err != nil {
+		t.Errorf("Subscribe() of an unbuffered RoundRobin member error = %v", err)
+	}
+}
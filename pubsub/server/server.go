@@ -0,0 +1,221 @@
+// Package server exposes a [pubsub.PubSub] over TCP to the clients of package client.
+//
+// Every client has its own subscriptions, which are removed when it disconnects.
+// Both sides send a ping every heartbeat interval, and close the connection
+// when they hear nothing from the other side for three intervals.
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/denpeshkov/doodles/pubsub"
+	"github.com/denpeshkov/doodles/pubsub/internal/wire"
+)
+
+// Server serves a [pubsub.PubSub] to network clients.
+type Server[T any] struct {
+	ps    *pubsub.PubSub[T]
+	codec pubsub.Codec
+	o     options
+}
+
+// Option configures a [Server].
+type Option func(*options)
+
+type options struct {
+	heartbeat time.Duration
+	bufSize   int
+	policy    pubsub.Policy
+}
+
+// WithHeartbeat sets the heartbeat interval. The default is 10 seconds.
+func WithHeartbeat(d time.Duration) Option {
+	return func(o *options) { o.heartbeat = d }
+}
+
+// WithBufferSize sets the buffer size of the subscriptions of the clients. The default is 64.
+func WithBufferSize(n int) Option {
+	return func(o *options) { o.bufSize = n }
+}
+
+// WithPolicy sets the policy of the subscriptions of the clients for a full buffer.
+// The default is [pubsub.DropOldest], so that a slow client does not stall the [pubsub.PubSub].
+// With [pubsub.Block], a slow client stalls every publisher for up to three heartbeat intervals,
+// after which its connection is closed.
+func WithPolicy(p pubsub.Policy) Option {
+	return func(o *options) { o.policy = p }
+}
+
+// New creates a [Server] of the PubSub, encoding the messages with the codec.
+// The PubSub must be running.
+func New[T any](ps *pubsub.PubSub[T], codec pubsub.Codec, opts ...Option) *Server[T] {
+	o := options{heartbeat: 10 * time.Second, bufSize: 64, policy: pubsub.DropOldest}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Server[T]{ps: ps, codec: codec, o: o}
+}
+
+// Serve serves the connections accepted on ln until the context is canceled.
+// It then closes ln and the connections, and returns once they are done.
+func (s *Server[T]) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(ctx, nc)
+		}()
+	}
+}
+
+// conn is a client connection.
+type conn[T any] struct {
+	s   *Server[T]
+	nc  net.Conn
+	wmu sync.Mutex
+}
+
+func (s *Server[T]) serveConn(ctx context.Context, nc net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	c := &conn[T]{s: s, nc: nc}
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		nc.Close()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.heartbeat(ctx, cancel)
+	}()
+	go func() {
+		<-ctx.Done()
+		nc.Close() // unblock the read
+	}()
+
+	subs := make(map[uint64]pubsub.Subscription[T])
+	defer func() {
+		for _, sub := range subs {
+			s.ps.Unsubscribe(sub)
+		}
+	}()
+	br := bufio.NewReader(nc)
+	for {
+		nc.SetReadDeadline(time.Now().Add(3 * s.o.heartbeat))
+		f, err := wire.Read(br)
+		if err != nil {
+			return
+		}
+		switch f.Type {
+		case wire.Ping:
+			err = c.write(wire.Frame{Type: wire.Pong})
+		case wire.Pong:
+		case wire.Subscribe:
+			if _, ok := subs[f.Sub]; ok {
+				err = c.reply(f.ID, errors.New("duplicate subscription ID"))
+				break
+			}
+			sub, subErr := s.ps.Subscribe(f.Topic, s.o.bufSize, pubsub.WithPolicy(s.o.policy))
+			if subErr == nil {
+				subs[f.Sub] = sub
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					c.forward(ctx, cancel, f.Sub, sub)
+				}()
+			}
+			err = c.reply(f.ID, subErr)
+		case wire.Unsubscribe:
+			if sub, ok := subs[f.Sub]; ok {
+				s.ps.Unsubscribe(sub)
+				delete(subs, f.Sub)
+			}
+			err = c.reply(f.ID, nil)
+		case wire.Publish:
+			var msg T
+			pubErr := s.codec.Unmarshal(f.Data, &msg)
+			if pubErr == nil {
+				pubErr = s.ps.Publish(ctx, f.Topic, msg)
+			}
+			err = c.reply(f.ID, pubErr)
+		default:
+			return // not speaking the protocol
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// forward writes the messages of the subscription to the client until it is unsubscribed.
+func (c *conn[T]) forward(ctx context.Context, cancel context.CancelFunc, id uint64, sub pubsub.Subscription[T]) {
+	for m := range sub.Updates() {
+		if ctx.Err() != nil {
+			continue // drain until the subscription is removed
+		}
+		data, err := c.s.codec.Marshal(m.Value)
+		if err != nil {
+			continue
+		}
+		if err := c.write(wire.Frame{Type: wire.Message, ID: id, Topic: m.Topic, Offset: m.Offset, Data: data}); err != nil {
+			cancel()
+		}
+	}
+}
+
+func (c *conn[T]) heartbeat(ctx context.Context, cancel context.CancelFunc) {
+	t := time.NewTicker(c.s.o.heartbeat)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.write(wire.Frame{Type: wire.Ping}); err != nil {
+				cancel()
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *conn[T]) reply(id uint64, err error) error {
+	f := wire.Frame{Type: wire.Reply, ID: id}
+	if err != nil {
+		f.Data = []byte(err.Error())
+	}
+	return c.write(f)
+}
+
+func (c *conn[T]) write(f wire.Frame) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.nc.SetWriteDeadline(time.Now().Add(3 * c.s.o.heartbeat))
+	return wire.Write(c.nc, f)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denpeshkov/doodles/pubsub"
+	"github.com/denpeshkov/doodles/pubsub/internal/wire"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestHeartbeat(t *testing.T) {
+	ps := pubsub.NewPubSub[string]()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ps.Run(ctx)
+	}()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		New(ps, pubsub.JSON, WithHeartbeat(20*time.Millisecond)).Serve(ctx, ln)
+	}()
+
+	// A client that never sends anything gets pings, and is disconnected after 3 intervals.
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	br := bufio.NewReader(nc)
+	start := time.Now()
+	var pings int
+	for {
+		f, err := wire.Read(br)
+		if err != nil {
+			break
+		}
+		if f.Type == wire.Ping {
+			pings++
+		}
+	}
+	if pings == 0 {
+		t.Errorf("received no pings")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("disconnected after %v, want about 60ms", elapsed)
+	}
+}
+
+func TestSlowClient(t *testing.T) {
+	ps := pubsub.NewPubSub[string]()
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ps.Run(ctx)
+	}()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		New(ps, pubsub.JSON, WithHeartbeat(time.Second)).Serve(ctx, ln)
+	}()
+
+	// A client that subscribes and never reads.
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	if err := wire.Write(nc, wire.Frame{Type: wire.Subscribe, ID: 1, Sub: 1, Topic: "orders"}); err != nil {
+		t.Fatal(err)
+	}
+	healthy, _ := ps.Subscribe("orders", 0)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		var n int
+		for range ps.Subscriptions() {
+			n++
+		}
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("the slow client did not subscribe")
+		}
+	}
+
+	// Enough to fill the socket buffers of the slow client many times over.
+	const n = 1000
+	msg := strings.Repeat("a", 16<<10)
+	received := make(chan int)
+	go func() {
+		var got int
+		for range healthy.Updates() {
+			if got++; got == n {
+				break
+			}
+		}
+		received <- got
+	}()
+	start := time.Now()
+	for range n {
+		if err := ps.Publish(ctx, "orders", msg); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	if got := <-received; got != n {
+		t.Errorf("the healthy subscription received %d messages, want %d", got, n)
+	}
+	// Blocking on the slow client would take until its write deadline of three heartbeats.
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("publishing took %v, want the slow client not to stall it", elapsed)
+	}
+}
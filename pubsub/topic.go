@@ -0,0 +1,158 @@
+package pubsub
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Topics are dot separated tokens, such as "orders.eu.created".
+// Patterns may also contain wildcard tokens: "*" matches a single token,
+// and ">", which must be the last token, matches one or more tokens.
+// For example, "orders.*" matches "orders.created" but not "orders.eu.created",
+// while "orders.>" matches both.
+const (
+	sep         = "."
+	anyToken    = "*"
+	anyTrailing = ">"
+)
+
+func checkTopic(topic string) error {
+	for _, tok := range strings.Split(topic, sep) {
+		if tok == "" || tok == anyToken || tok == anyTrailing {
+			return fmt.Errorf("invalid topic %q", topic)
+		}
+	}
+	return nil
+}
+
+func checkPattern(pattern string) error {
+	toks := strings.Split(pattern, sep)
+	for i, tok := range toks {
+		if tok == "" || (tok == anyTrailing && i != len(toks)-1) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// trie indexes subscriptions by the tokens of their patterns.
+type trie[T any] struct {
+	children map[string]*trie[T]
+	subs     []Subscription[T]    // subscriptions with the pattern ending here
+	queues   map[string]*queue[T] // queue groups with the pattern ending here, by name
+}
+
+func (t *trie[T]) insert(pattern string, sub Subscription[T]) {
+	n := t
+	for _, tok := range strings.Split(pattern, sep) {
+		c, ok := n.children[tok]
+		if !ok {
+			if n.children == nil {
+				n.children = make(map[string]*trie[T])
+			}
+			c = &trie[T]{}
+			n.children[tok] = c
+		}
+		n = c
+	}
+	if sub.state.queue == "" {
+		n.subs = append(n.subs, sub)
+		return
+	}
+	q, ok := n.queues[sub.state.queue]
+	if !ok {
+		if n.queues == nil {
+			n.queues = make(map[string]*queue[T])
+		}
+		q = &queue[T]{balance: sub.state.balance}
+		n.queues[sub.state.queue] = q
+	}
+	q.members = append(q.members, sub)
+}
+
+// find returns the node of the pattern, or nil if there is none.
+func (t *trie[T]) find(pattern string) *trie[T] {
+	n := t
+	for _, tok := range strings.Split(pattern, sep) {
+		if n = n.children[tok]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// remove removes the subscription with the pattern, pruning the nodes left empty.
+func (t *trie[T]) remove(toks []string, sub Subscription[T]) {
+	if len(toks) == 0 {
+		if sub.state.queue == "" {
+			t.subs = slices.DeleteFunc(t.subs, func(s Subscription[T]) bool { return s == sub })
+			return
+		}
+		if q, ok := t.queues[sub.state.queue]; ok {
+			q.members = slices.DeleteFunc(q.members, func(s Subscription[T]) bool { return s == sub })
+			if len(q.members) == 0 {
+				delete(t.queues, sub.state.queue)
+			}
+		}
+		return
+	}
+	c, ok := t.children[toks[0]]
+	if !ok {
+		return
+	}
+	c.remove(toks[1:], sub)
+	if len(c.subs) == 0 && len(c.children) == 0 && len(c.queues) == 0 {
+		delete(t.children, toks[0])
+	}
+}
+
+// match calls f for every subscription with a pattern matching the topic tokens,
+// and for a single member of every queue group with a matching pattern.
+// Every pattern is a single path in the trie, so f is called at most once per subscription.
+func (t *trie[T]) match(toks []string, f func(Subscription[T]) bool) bool {
+	if len(toks) == 0 {
+		for _, sub := range t.subs {
+			if !f(sub) {
+				return false
+			}
+		}
+		for _, q := range t.queues {
+			if !f(q.pick()) {
+				return false
+			}
+		}
+		return true
+	}
+	if c, ok := t.children[anyTrailing]; ok {
+		if !c.match(nil, f) {
+			return false
+		}
+	}
+	if c, ok := t.children[toks[0]]; ok {
+		if !c.match(toks[1:], f) {
+			return false
+		}
+	}
+	if c, ok := t.children[anyToken]; ok {
+		if !c.match(toks[1:], f) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the pattern tokens match the topic tokens.
+func matches(pattern, topic []string) bool {
+	for i, tok := range pattern {
+		switch {
+		case tok == anyTrailing:
+			return i < len(topic)
+		case i == len(topic):
+			return false
+		case tok != anyToken && tok != topic[i]:
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
@@ -0,0 +1,248 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Outcome is the outcome of an operation admitted by an [Adaptive] limiter.
+type Outcome int
+
+const (
+	// Success means that the operation succeeded.
+	Success Outcome = iota
+	// Dropped means that the operation failed because of overload, such as a timeout or a rejection.
+	Dropped
+	// Ignored means that the operation failed for a reason unrelated to load. It does not change the limit.
+	Ignored
+)
+
+// Sample is an observation of an operation admitted by an [Adaptive] limiter.
+type Sample struct {
+	RTT      time.Duration // latency of the operation
+	Inflight int           // operations in flight when it was admitted, including itself
+	Outcome  Outcome
+}
+
+// Algorithm adjusts a concurrency limit from samples.
+// It is called under the lock of the limiter, so it need not be safe for concurrent use.
+type Algorithm interface {
+	// Update returns the new limit given the current one and a sample.
+	Update(limit float64, s Sample) float64
+}
+
+// AIMD increases the limit additively while operations succeed,
+// and decreases it multiplicatively when they are dropped, like TCP congestion control.
+type AIMD struct {
+	// Backoff is the factor of the decrease. The default is 0.9.
+	Backoff float64
+	// Timeout is the latency over which an operation is considered dropped. Zero means no timeout.
+	Timeout time.Duration
+}
+
+func (a *AIMD) Update(limit float64, s Sample) float64 {
+	if s.Outcome == Dropped || (a.Timeout > 0 && s.RTT > a.Timeout) {
+		backoff := a.Backoff
+		if backoff == 0 {
+			backoff = 0.9
+		}
+		return limit * backoff
+	}
+	if s.Outcome != Success || float64(s.Inflight) < limit/2 {
+		return limit // no evidence that a higher limit is needed
+	}
+	return limit + 1/limit // one more per limit of successful operations
+}
+
+// Vegas estimates the number of operations queued at the backend from the increase of the latency
+// over the minimum one seen, like TCP Vegas, and keeps it between Alpha and Beta.
+// The minimum latency is never forgotten, so it is not suitable for backends that get slower for good.
+type Vegas struct {
+	// Alpha and Beta bound the estimated queue, with Alpha <= Beta.
+	// The defaults are 3 and 6, or Beta and Alpha if only the other one is set and out of that range.
+	Alpha, Beta float64
+	// Backoff is the factor of the decrease when an operation is dropped. The default is 0.9.
+	Backoff float64
+
+	minRTT time.Duration
+}
+
+func (v *Vegas) Update(limit float64, s Sample) float64 {
+	switch s.Outcome {
+	case Ignored:
+		return limit
+	case Dropped:
+		backoff := v.Backoff
+		if backoff == 0 {
+			backoff = 0.9
+		}
+		return limit * backoff
+	}
+	if s.RTT <= 0 {
+		return limit
+	}
+	if v.minRTT == 0 || s.RTT < v.minRTT {
+		v.minRTT = s.RTT
+	}
+	alpha, beta := v.bounds()
+
+	queue := limit * (1 - float64(v.minRTT)/float64(s.RTT))
+	switch {
+	case queue < alpha:
+		return limit + 1/limit
+	case queue > beta:
+		return limit - 1/limit
+	}
+	return limit
+}
+
+func (v *Vegas) bounds() (alpha, beta float64) {
+	alpha, beta = v.Alpha, v.Beta
+	if alpha < 0 || beta < 0 {
+		panic("rate: vegas alpha and beta must be >= 0")
+	}
+	switch {
+	case alpha == 0 && beta == 0:
+		alpha, beta = 3, 6
+	case alpha == 0:
+		alpha = min(3, beta)
+	case beta == 0:
+		beta = max(6, alpha)
+	case alpha > beta:
+		panic("rate: vegas alpha must be <= beta")
+	}
+	return alpha, beta
+}
+
+// AdaptiveConfig configures an [Adaptive] limiter.
+type AdaptiveConfig struct {
+	Algorithm Algorithm
+	// InitialLimit is the limit to start with. The default is 10.
+	InitialLimit int
+	// MinLimit and MaxLimit bound the limit. The defaults are 1 and no bound.
+	MinLimit, MaxLimit int
+}
+
+// Adaptive limits the number of operations in flight, adjusting the limit with an [Algorithm]
+// from the latency and the outcome of the operations.
+// It is safe for concurrent use.
+type Adaptive struct {
+	now      func() time.Time
+	alg      Algorithm
+	minLimit float64
+	maxLimit float64
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	released chan struct{} // closed and replaced when an operation is released
+}
+
+// NewAdaptive creates a new [Adaptive] limiter.
+func NewAdaptive(cfg AdaptiveConfig, opts ...Option) *Adaptive {
+	if cfg.Algorithm == nil {
+		panic("rate: algorithm must be set")
+	}
+	if cfg.InitialLimit < 0 || cfg.MinLimit < 0 || cfg.MaxLimit < 0 {
+		panic("rate: limits must be >= 0")
+	}
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	a := &Adaptive{
+		now:      o.now,
+		alg:      cfg.Algorithm,
+		minLimit: float64(max(cfg.MinLimit, 1)),
+		maxLimit: math.Inf(1),
+		limit:    float64(orDefault(cfg.InitialLimit, 10)),
+		released: make(chan struct{}),
+	}
+	if cfg.MaxLimit > 0 {
+		a.maxLimit = float64(cfg.MaxLimit)
+	}
+	if a.minLimit > a.maxLimit {
+		panic("rate: min limit must be <= max limit")
+	}
+	a.limit = min(max(a.limit, a.minLimit), a.maxLimit)
+	return a
+}
+
+// Limit returns the current limit.
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// Inflight returns the number of operations in flight.
+func (a *Adaptive) Inflight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
+
+// Acquire blocks until the number of operations in flight is under the limit, and admits an operation.
+// The caller must call release exactly once with the outcome of the operation.
+// It returns an error if the context is canceled.
+func (a *Adaptive) Acquire(ctx context.Context) (release func(Outcome), err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		var ok bool
+		var released <-chan struct{}
+		release, ok, released = a.tryAcquire()
+		if ok {
+			return release, nil
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// TryAcquire is like [Adaptive.Acquire], but reports false instead of blocking.
+func (a *Adaptive) TryAcquire() (release func(Outcome), ok bool) {
+	release, ok, _ = a.tryAcquire()
+	return release, ok
+}
+
+// tryAcquire admits an operation if possible,
+// returning otherwise a channel closed when an operation is released.
+func (a *Adaptive) tryAcquire() (func(Outcome), bool, <-chan struct{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if float64(a.inflight) >= math.Floor(a.limit) {
+		return nil, false, a.released
+	}
+	a.inflight++
+	inflight, start := a.inflight, a.now()
+
+	var once sync.Once
+	release := func(outcome Outcome) {
+		once.Do(func() { a.release(Sample{RTT: a.now().Sub(start), Inflight: inflight, Outcome: outcome}) })
+	}
+	return release, true, nil
+}
+
+func (a *Adaptive) release(s Sample) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.inflight--
+	a.limit = min(max(a.alg.Update(a.limit, s), a.minLimit), a.maxLimit)
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+// orDefault returns v if it is set, and def otherwise.
+func orDefault(v, def int) int {
+	if v == 0 {
+		return def
+	}
+	return v
+}
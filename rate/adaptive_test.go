@@ -0,0 +1,156 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// backend serves capacity operations at once in service time, and queues the others.
+// Operations slower than timeout are dropped.
+type backend struct {
+	capacity int
+	service  time.Duration
+	timeout  time.Duration
+}
+
+func (b backend) serve(inflight int) (time.Duration, Outcome) {
+	latency := b.service * time.Duration(max(inflight, b.capacity)) / time.Duration(b.capacity)
+	if latency > b.timeout {
+		return b.timeout, Dropped
+	}
+	return latency, Success
+}
+
+// simulate runs clients with unbounded demand through the limiter against the backend for d,
+// in steps of a millisecond.
+func simulate(clock *fakeClock, a *Adaptive, b backend, d time.Duration) {
+	type op struct {
+		done    time.Time
+		outcome Outcome
+		release func(Outcome)
+	}
+	var pending []op
+	for end := clock.Now().Add(d); clock.Now().Before(end); clock.Advance(time.Millisecond) {
+		var next []op
+		for _, o := range pending {
+			if o.done.After(clock.Now()) {
+				next = append(next, o)
+				continue
+			}
+			o.release(o.outcome)
+		}
+		pending = next
+
+		for {
+			release, ok := a.TryAcquire()
+			if !ok {
+				break
+			}
+			latency, outcome := b.serve(a.Inflight())
+			pending = append(pending, op{clock.Now().Add(latency), outcome, release})
+		}
+	}
+	for _, o := range pending {
+		o.release(Ignored)
+	}
+}
+
+func TestAdaptiveSimulation(t *testing.T) {
+	healthy := backend{capacity: 50, service: 10 * time.Millisecond, timeout: 200 * time.Millisecond}
+	slow := backend{capacity: 10, service: 50 * time.Millisecond, timeout: 200 * time.Millisecond}
+
+	algorithms := map[string]func() Algorithm{
+		"AIMD":  func() Algorithm { return &AIMD{} },
+		"Vegas": func() Algorithm { return &Vegas{} },
+	}
+	for name, alg := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			clock := &fakeClock{now: t0}
+			a := NewAdaptive(AdaptiveConfig{Algorithm: alg(), MaxLimit: 200}, WithClock(clock.Now))
+
+			simulate(clock, a, healthy, 10*time.Second)
+			before := a.Limit()
+			simulate(clock, a, slow, 10*time.Second)
+			during := a.Limit()
+			simulate(clock, a, healthy, 10*time.Second)
+			after := a.Limit()
+			t.Logf("limit: healthy %d, slow %d, recovered %d", before, during, after)
+
+			if during > before/2 {
+				t.Errorf("limit = %d with a slow backend, want at most half of %d", during, before)
+			}
+			if after < 2*during {
+				t.Errorf("limit = %d after the recovery, want at least twice %d", after, during)
+			}
+			if a.Inflight() != 0 {
+				t.Errorf("Inflight() = %d, want 0", a.Inflight())
+			}
+		})
+	}
+}
+
+func TestVegasBounds(t *testing.T) {
+	tests := []struct {
+		v                   Vegas
+		wantAlpha, wantBeta float64
+	}{
+		{Vegas{}, 3, 6},
+		{Vegas{Beta: 10}, 3, 10},
+		{Vegas{Beta: 2}, 2, 2},
+		{Vegas{Alpha: 1}, 1, 6},
+		{Vegas{Alpha: 8}, 8, 8},
+		{Vegas{Alpha: 1, Beta: 2}, 1, 2},
+	}
+	for _, tt := range tests {
+		alpha, beta := tt.v.bounds()
+		if alpha != tt.wantAlpha || beta != tt.wantBeta {
+			t.Errorf("%+v: bounds() = %v, %v, want %v, %v", tt.v, alpha, beta, tt.wantAlpha, tt.wantBeta)
+		}
+	}
+
+	// With only Beta set, the limit still grows while there is no queue.
+	v := &Vegas{Beta: 10}
+	if limit := v.Update(10, Sample{RTT: time.Millisecond}); limit <= 10 {
+		t.Errorf("Update() = %v, want more than 10", limit)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("bounds() did not panic with Alpha > Beta")
+		}
+	}()
+	(&Vegas{Alpha: 3, Beta: 2}).bounds()
+}
+
+func TestAdaptiveAcquire(t *testing.T) {
+	a := NewAdaptive(AdaptiveConfig{Algorithm: &AIMD{}, InitialLimit: 1, MaxLimit: 1})
+	release, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := a.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	done := make(chan error)
+	go func() {
+		release, err := a.Acquire(context.Background())
+		if err == nil {
+			release(Success)
+		}
+		done <- err
+	}()
+	release(Success)
+	release(Success) // releasing twice does nothing
+	if err := <-done; err != nil {
+		t.Errorf("Acquire() error = %v after a release", err)
+	}
+	if a.Inflight() != 0 {
+		t.Errorf("Inflight() = %d, want 0", a.Inflight())
+	}
+}
@@ -0,0 +1,54 @@
+package rate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// GCRA is a limiter using the generic cell rate algorithm.
+// It allows the same operations as a token bucket with the same limit and burst,
+// but keeps only the theoretical arrival time of the next operation.
+type GCRA struct {
+	now       func() time.Time
+	interval  time.Duration // emission interval, the time between operations at the limit
+	tolerance time.Duration // how early an operation may come, the burst less one in intervals
+
+	mu  sync.Mutex
+	tat time.Time // theoretical arrival time of the next operation
+}
+
+// NewGCRA creates a [GCRA] allowing operations at the given rate, with bursts of at most burst operations.
+func NewGCRA(limit Limit, burst int, opts ...Option) *GCRA {
+	checkLimit(limit)
+	checkBurst(burst)
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	interval := time.Duration(float64(time.Second) / float64(limit))
+	return &GCRA{now: o.now, interval: interval, tolerance: time.Duration(burst-1) * interval}
+}
+
+func (g *GCRA) CanTake() bool {
+	return g.take(g.now()) == 0
+}
+
+// Wait blocks until an operation is allowed.
+func (g *GCRA) Wait(ctx context.Context) error {
+	return wait(ctx, g.now, g.take)
+}
+
+func (g *GCRA) take(now time.Time) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	tat := g.tat
+	if tat.Before(now) {
+		tat = now
+	}
+	if d := tat.Sub(now) - g.tolerance; d > 0 {
+		return d
+	}
+	g.tat = tat.Add(g.interval)
+	return 0
+}
@@ -0,0 +1,63 @@
+package rate
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeyFunc returns the key that a request is limited by.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by the IP address of the client.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ByHeader keys requests by the value of the header, such as an API key.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) string { return r.Header.Get(name) }
+}
+
+// Middleware limits the requests of every key with the limiters of k.
+//
+// Every response has the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers,
+// with the burst, the remaining requests and the seconds until the limiter is full.
+// A request over the limit is rejected with 429 Too Many Requests
+// and a Retry-After header with the seconds until it would be allowed.
+func Middleware(k *Keyed[string], key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			l := k.Limiter(key(r))
+			now := l.now()
+			res := l.ReserveN(now, 1)
+			delay := res.DelayFrom(now)
+			if delay > 0 {
+				res.CancelAt(now)
+			}
+
+			burst, tokens := l.Burst(), l.TokensAt(now)
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(burst))
+			h.Set("RateLimit-Remaining", strconv.Itoa(max(int(tokens), 0)))
+			h.Set("RateLimit-Reset", seconds(time.Duration((float64(burst)-tokens)/float64(l.Limit())*float64(time.Second))))
+			if delay > 0 {
+				h.Set("Retry-After", seconds(delay))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds formats d as whole seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(math.Ceil(d.Seconds()), 'f', 0, 64)
+}
@@ -0,0 +1,83 @@
+package rate
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	k := NewKeyed[string](KeyedConfig{Limit: 1, Burst: 2}, WithClock(clock.Now))
+	defer k.Close()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := Middleware(k, ByHeader("X-API-Key"))(ok)
+
+	tests := []struct {
+		advance    time.Duration
+		key        string
+		code       int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{0, "a", http.StatusOK, "1", "1", ""},
+		{0, "a", http.StatusOK, "0", "2", ""},
+		{0, "a", http.StatusTooManyRequests, "0", "2", "1"},
+		{0, "b", http.StatusOK, "1", "1", ""}, // every key has its own limiter
+		{time.Second, "a", http.StatusOK, "0", "2", ""},
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", tt.key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.code {
+			t.Errorf("#%d: code = %d, want %d", i, rec.Code, tt.code)
+		}
+		for name, want := range map[string]string{
+			"RateLimit-Limit":     "2",
+			"RateLimit-Remaining": tt.remaining,
+			"RateLimit-Reset":     tt.reset,
+			"Retry-After":         tt.retryAfter,
+		} {
+			if got := rec.Header().Get(name); got != want {
+				t.Errorf("#%d: %s = %q, want %q", i, name, got, want)
+			}
+		}
+	}
+}
+
+func TestByIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if got := ByIP(req); got != "192.0.2.1" {
+		t.Errorf("ByIP() = %q, want %q", got, "192.0.2.1")
+	}
+}
+
+func TestListener(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Listener = NewListener(srv.Listener, NewLimiter(100, 1))
+	srv.Start()
+	defer srv.Close()
+
+	// Every request opens a new connection.
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	start := time.Now()
+	for range 3 {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("3 connections took %v, want at least 20ms", elapsed)
+	}
+}
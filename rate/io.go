@@ -0,0 +1,60 @@
+package rate
+
+import (
+	"context"
+	"io"
+)
+
+// Reader limits the throughput of an [io.Reader] to the limit of a [RateLimiter] in bytes per second.
+type Reader struct {
+	r io.Reader
+	l *RateLimiter
+}
+
+// NewReader creates a [Reader] reading from r.
+// A single Read reads at most the burst of l bytes.
+func NewReader(r io.Reader, l *RateLimiter) *Reader {
+	return &Reader{r: r, l: l}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n, err := r.r.Read(p[:min(len(p), r.l.Burst())])
+	if n > 0 {
+		if err := r.l.WaitN(context.Background(), n); err != nil {
+			return n, err
+		}
+	}
+	return n, err
+}
+
+// Writer limits the throughput of an [io.Writer] to the limit of a [RateLimiter] in bytes per second.
+type Writer struct {
+	w io.Writer
+	l *RateLimiter
+}
+
+// NewWriter creates a [Writer] writing to w.
+// Writes are split into chunks of at most the burst of l bytes.
+func NewWriter(w io.Writer, l *RateLimiter) *Writer {
+	return &Writer{w: w, l: l}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), w.l.Burst())]
+		if err := w.l.WaitN(context.Background(), len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
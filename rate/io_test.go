@@ -0,0 +1,42 @@
+package rate
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	r := NewReader(bytes.NewReader(data), NewLimiter(10_000, 100))
+	start := time.Now()
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("read %d bytes, want %d", len(got), len(data))
+	}
+	// The first 100 bytes are the burst, the other 900 come at 10KB/s.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("reading took %v, want at least 90ms", elapsed)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, NewLimiter(10_000, 100))
+	start := time.Now()
+	n, err := w.Write(data)
+	if err != nil || n != len(data) {
+		t.Fatalf("Write() = %d, %v, want %d, nil", n, err, len(data))
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("wrote %d bytes, want %d", buf.Len(), len(data))
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("writing took %v, want at least 90ms", elapsed)
+	}
+}
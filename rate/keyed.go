@@ -0,0 +1,139 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/denpeshkov/doodles/lru"
+)
+
+// KeyedConfig configures a [Keyed].
+type KeyedConfig struct {
+	// Limit and Burst are the parameters of every limiter.
+	Limit Limit
+	Burst int
+	// IdleTTL is how long a limiter is kept after its last use and after it has refilled to Burst,
+	// so evicting it never lets a key exceed the limit. Zero means forever.
+	IdleTTL time.Duration
+	// MaxKeys bounds the number of limiters by evicting the least recently used ones.
+	// Zero means no bound.
+	MaxKeys int
+}
+
+// Keyed is a registry of rate limiters, one per key, such as an API key or a client IP.
+// Limiters are created on demand and evicted when idle or when there are too many of them.
+// An evicted limiter is forgotten, so the next use of its key starts with a full limiter.
+// Idle limiters are only evicted once they have refilled, so this never loosens the limit.
+// It is safe for concurrent use.
+type Keyed[K comparable] struct {
+	cfg  KeyedConfig
+	opts []Option
+	now  func() time.Time
+
+	mu       sync.Mutex
+	limiters *lru.LRU[K, *RateLimiter]
+	stop     func() // stops the janitor, nil if there is none
+}
+
+// NewKeyed creates a new [Keyed]. The options are applied to every limiter.
+// If cfg.IdleTTL is set, idle limiters are removed in the background until [Keyed.Close] is called.
+func NewKeyed[K comparable](cfg KeyedConfig, opts ...Option) *Keyed[K] {
+	checkLimit(cfg.Limit)
+	checkBurst(cfg.Burst)
+	if cfg.IdleTTL < 0 {
+		panic("rate: idle TTL must be >= 0")
+	}
+	if cfg.MaxKeys < 0 {
+		panic("rate: max keys must be >= 0")
+	}
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	lruOpts := []lru.Option{lru.WithClock(o.now)}
+	var limiters *lru.LRU[K, *RateLimiter]
+	if cfg.MaxKeys > 0 {
+		limiters = lru.New[K, *RateLimiter](cfg.MaxKeys, lruOpts...)
+	} else {
+		// Unbounded: every limiter costs nothing out of an infinite budget.
+		limiters = lru.NewWithCost[K](math.MaxInt64, func(*RateLimiter) int64 { return 1 }, lruOpts...)
+	}
+	k := &Keyed[K]{cfg: cfg, opts: opts, now: o.now, limiters: limiters}
+	if cfg.IdleTTL > 0 {
+		k.stop = limiters.StartJanitor(cfg.IdleTTL, &k.mu)
+	}
+	return k
+}
+
+// Allow reports whether an operation for the key is allowed now.
+func (k *Keyed[K]) Allow(key K) bool {
+	l := k.limiter(key)
+	ok := l.CanTake()
+	k.touch(key, l)
+	return ok
+}
+
+// Wait blocks until an operation for the key is allowed. See [RateLimiter.Wait].
+func (k *Keyed[K]) Wait(ctx context.Context, key K) error {
+	l := k.limiter(key)
+	err := l.Wait(ctx)
+	k.touch(key, l)
+	return err
+}
+
+// Limiter returns the limiter for the key, creating it if needed, and marks it as used.
+// It is kept for long enough to refill after taking a whole burst from it.
+func (k *Keyed[K]) Limiter(key K) *RateLimiter {
+	return k.limiter(key)
+}
+
+// Len returns the number of limiters, including idle ones not yet removed.
+func (k *Keyed[K]) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.limiters.Len()
+}
+
+// Close stops removing idle limiters in the background.
+func (k *Keyed[K]) Close() {
+	if k.stop != nil {
+		k.stop()
+	}
+}
+
+// limiter returns the limiter for the key, creating it if needed, and marks it as used.
+// Its caller may take up to a burst from it before calling [Keyed.touch].
+func (k *Keyed[K]) limiter(key K) *RateLimiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, ok := k.limiters.Get(key)
+	if !ok {
+		l = NewLimiter(k.cfg.Limit, k.cfg.Burst, k.opts...)
+	}
+	k.put(key, l, float64(k.cfg.Burst))
+	return l
+}
+
+// touch marks the limiter for the key as used after an operation on it.
+// If it was evicted meanwhile, it is added back.
+func (k *Keyed[K]) touch(key K, l *RateLimiter) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if cur, ok := k.limiters.Peek(key); ok && cur != l {
+		return // another caller has replaced it already
+	}
+	k.put(key, l, 0)
+}
+
+// put stores the limiter, keeping it for the idle TTL after it refills
+// from its current tokens less the reserve.
+func (k *Keyed[K]) put(key K, l *RateLimiter, reserve float64) {
+	ttl := time.Duration(0)
+	if k.cfg.IdleTTL > 0 {
+		ttl = k.cfg.IdleTTL + l.Limit().durationFromTokens(float64(l.Burst())-l.TokensAt(k.now())+reserve)
+	}
+	k.limiters.PutWithTTL(key, l, ttl)
+}
@@ -0,0 +1,134 @@
+package rate
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyedAllow(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	k := NewKeyed[string](KeyedConfig{Limit: 1, Burst: 2}, WithClock(clock.Now))
+	defer k.Close()
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"a", true},
+		{"a", true},
+		{"a", false},
+		{"b", true}, // every key has its own limiter
+		{"b", true},
+		{"b", false},
+	}
+	for i, tt := range tests {
+		if got := k.Allow(tt.key); got != tt.want {
+			t.Errorf("#%d: Allow(%q) = %t, want %t", i, tt.key, got, tt.want)
+		}
+	}
+	if k.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", k.Len())
+	}
+}
+
+func TestKeyedIdleTTL(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	k := NewKeyed[string](KeyedConfig{Limit: Every(time.Minute), Burst: 1, IdleTTL: time.Minute}, WithClock(clock.Now))
+	defer k.Close()
+
+	k.Allow("a")
+	clock.Advance(50 * time.Second)
+	if k.Allow("a") {
+		t.Errorf("Allow(a) = true, want the limiter to be kept while in use")
+	}
+	clock.Advance(50 * time.Second) // refilled, but only 50s since the last use
+	if !k.Allow("a") {
+		t.Errorf("Allow(a) = false, want the limiter to have refilled")
+	}
+	if k.Len() != 1 {
+		t.Errorf("Len() = %d, want the idle TTL to be refreshed on use", k.Len())
+	}
+
+	clock.Advance(2*time.Minute - time.Second) // refilled 1s ago, idle for less than the TTL
+	k.limiters.RemoveExpired()
+	if k.Len() != 1 {
+		t.Errorf("Len() = %d, want the limiter to be kept for the idle TTL after it refills", k.Len())
+	}
+	clock.Advance(time.Minute)
+	k.limiters.RemoveExpired()
+	if k.Len() != 0 {
+		t.Errorf("Len() = %d, want the limiter to be evicted after the idle TTL", k.Len())
+	}
+}
+
+func TestKeyedIdleTTLKeepsLimit(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	k := NewKeyed[string](KeyedConfig{Limit: Every(time.Hour), Burst: 1, IdleTTL: time.Minute}, WithClock(clock.Now))
+	defer k.Close()
+
+	allowed := 0
+	for range 60 {
+		if k.Allow("a") {
+			allowed++
+		}
+		clock.Advance(time.Minute)
+		k.limiters.RemoveExpired()
+	}
+	if allowed != 1 {
+		t.Errorf("allowed %d operations in an hour, want 1", allowed)
+	}
+	clock.Advance(time.Minute)
+	if !k.Allow("a") {
+		t.Errorf("Allow(a) = false after an hour, want true")
+	}
+}
+
+func TestKeyedMaxKeys(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	k := NewKeyed[int](KeyedConfig{Limit: Every(time.Hour), Burst: 1, MaxKeys: 2}, WithClock(clock.Now))
+	defer k.Close()
+
+	k.Allow(1)
+	k.Allow(2)
+	k.Allow(1)
+	k.Allow(3) // evicts 2
+	if k.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", k.Len())
+	}
+	if k.Allow(1) {
+		t.Errorf("Allow(1) = true, want the limiter of 1 to be kept")
+	}
+	if !k.Allow(2) {
+		t.Errorf("Allow(2) = false, want a new limiter for the evicted key")
+	}
+}
+
+func TestKeyedJanitor(t *testing.T) {
+	k := NewKeyed[int](KeyedConfig{Limit: 1, Burst: 1, IdleTTL: 10 * time.Millisecond})
+	defer k.Close()
+	for i := range 10 {
+		k.Allow(i)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for k.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor left %d limiters", k.Len())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestKeyedWait(t *testing.T) {
+	k := NewKeyed[string](KeyedConfig{Limit: 100, Burst: 1})
+	defer k.Close()
+	start := time.Now()
+	for range 3 {
+		if err := k.Wait(context.Background(), "a"); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("3 waits took %v, want about 20ms", elapsed)
+	}
+}
@@ -0,0 +1,47 @@
+package rate
+
+import (
+	"context"
+	"time"
+)
+
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*SlidingLog)(nil)
+	_ Limiter = (*SlidingWindow)(nil)
+	_ Limiter = (*GCRA)(nil)
+)
+
+// Limiter limits the rate of operations.
+type Limiter interface {
+	// CanTake reports whether an operation is allowed now, counting it if it is.
+	CanTake() bool
+	// Wait blocks until an operation is allowed.
+	// It returns an error if the context is canceled, or if its deadline is too near.
+	Wait(ctx context.Context) error
+}
+
+// wait blocks until take succeeds. take takes an operation if it is allowed at now,
+// and otherwise returns how long to wait before trying again.
+func wait(ctx context.Context, now func() time.Time, take func(now time.Time) time.Duration) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t := now()
+		d := take(t)
+		if d <= 0 {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(t.Add(d)) {
+			return ErrWouldExceedDeadline
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type step struct {
+	at   time.Duration // since t0
+	want bool
+}
+
+func testAdmissions(t *testing.T, clock *fakeClock, l Limiter, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		clock.now = t0.Add(s.at)
+		if got := l.CanTake(); got != s.want {
+			t.Errorf("#%d at %v: CanTake() = %t, want %t", i, s.at, got, s.want)
+		}
+	}
+}
+
+func TestSlidingLog(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	l := NewSlidingLog(2, time.Second, WithClock(clock.Now))
+	testAdmissions(t, clock, l, []step{
+		{0, true},
+		{400 * time.Millisecond, true},
+		{600 * time.Millisecond, false},
+		{time.Second, true},              // the operation at 0 left the window
+		{1200 * time.Millisecond, false}, // the one at 400ms did not
+		{1399 * time.Millisecond, false},
+		{1400 * time.Millisecond, true},
+		{5 * time.Second, true},
+		{5 * time.Second, true},
+		{5 * time.Second, false},
+	})
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	l := NewSlidingWindow(4, time.Second, WithClock(clock.Now))
+	testAdmissions(t, clock, l, []step{
+		{0, true},
+		{100 * time.Millisecond, true},
+		{200 * time.Millisecond, true},
+		{300 * time.Millisecond, true},
+		{400 * time.Millisecond, false},  // the current window is full
+		{time.Second, false},             // the previous window counts fully
+		{1250 * time.Millisecond, true},  // 4*0.75 + 1
+		{1500 * time.Millisecond, true},  // 4*0.5 + 2
+		{1500 * time.Millisecond, false}, // 4*0.5 + 3
+		{1750 * time.Millisecond, true},  // 4*0.25 + 3
+		{3100 * time.Millisecond, true},  // the previous window is empty
+		{3100 * time.Millisecond, true},
+		{3100 * time.Millisecond, true},
+		{3100 * time.Millisecond, true},
+		{3100 * time.Millisecond, false},
+	})
+}
+
+func TestGCRA(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	l := NewGCRA(10, 3, WithClock(clock.Now))
+	testAdmissions(t, clock, l, []step{
+		{0, true},
+		{0, true},
+		{0, true},
+		{0, false}, // the burst is used up
+		{50 * time.Millisecond, false},
+		{100 * time.Millisecond, true},
+		{100 * time.Millisecond, false},
+		{time.Second, true}, // refilled
+		{time.Second, true},
+		{time.Second, true},
+		{time.Second, false},
+	})
+}
+
+func TestLimiterWait(t *testing.T) {
+	limiters := map[string]func() Limiter{
+		"RateLimiter":   func() Limiter { return NewLimiter(100, 1) },
+		"SlidingLog":    func() Limiter { return NewSlidingLog(1, 10*time.Millisecond) },
+		"SlidingWindow": func() Limiter { return NewSlidingWindow(1, 10*time.Millisecond) },
+		"GCRA":          func() Limiter { return NewGCRA(100, 1) },
+	}
+	for name, newLimiter := range limiters {
+		t.Run(name, func(t *testing.T) {
+			l := newLimiter()
+			start := time.Now()
+			for range 3 {
+				if err := l.Wait(context.Background()); err != nil {
+					t.Fatalf("Wait() error = %v", err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+				t.Errorf("3 waits took %v, want at least 20ms", elapsed)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			l.CanTake()
+			if err := l.Wait(ctx); !errors.Is(err, ErrWouldExceedDeadline) {
+				t.Errorf("Wait() error = %v, want %v", err, ErrWouldExceedDeadline)
+			}
+		})
+	}
+}
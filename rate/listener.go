@@ -0,0 +1,39 @@
+package rate
+
+import (
+	"context"
+	"net"
+)
+
+// Listener throttles the connections accepted by a [net.Listener].
+type Listener struct {
+	net.Listener
+	l Limiter
+
+	ctx    context.Context // canceled on Close
+	cancel context.CancelFunc
+}
+
+// NewListener creates a [Listener] accepting the connections of ln as allowed by l.
+// Connections waiting to be accepted stay in the backlog of ln.
+func NewListener(ln net.Listener, l Limiter) *Listener {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Listener{Listener: ln, l: l, ctx: ctx, cancel: cancel}
+}
+
+// Accept waits until a connection is allowed and then accepts it.
+func (ln *Listener) Accept() (net.Conn, error) {
+	if err := ln.l.Wait(ln.ctx); err != nil {
+		if ln.ctx.Err() != nil {
+			return nil, net.ErrClosed
+		}
+		return nil, err
+	}
+	return ln.Listener.Accept()
+}
+
+// Close closes the listener, unblocking a waiting Accept.
+func (ln *Listener) Close() error {
+	ln.cancel()
+	return ln.Listener.Close()
+}
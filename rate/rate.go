@@ -1,50 +1,197 @@
 package rate
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"time"
 )
 
+// ErrWouldExceedDeadline is returned by [RateLimiter.Wait] and [RateLimiter.WaitN]
+// when the context deadline is too near to get the tokens.
+var ErrWouldExceedDeadline = errors.New("rate: wait would exceed context deadline")
+
+// Limit is the maximum rate of operations per second.
+type Limit float64
+
+// Every converts the minimum interval between operations to a [Limit].
+func Every(interval time.Duration) Limit {
+	if interval <= 0 {
+		panic("rate: interval must be positive")
+	}
+	return Limit(float64(time.Second) / float64(interval))
+}
+
 type RateLimiter struct {
-	limit float64
+	now func() time.Time
+
+	mu        sync.Mutex
+	limit     Limit
+	burst     int
+	tokens    float64 // negative if there are pending reservations
+	last      time.Time
+	lastEvent time.Time // latest time to act of the reservations
+}
 
-	mu     sync.Mutex
-	tokens float64
-	last   time.Time
+// Option configures a [RateLimiter].
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+// WithClock sets the function used to get the current time.
+// The default is [time.Now].
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
 }
 
+// NewRateLimiter creates a [RateLimiter] allowing limit operations per second with a burst of 1.
 func NewRateLimiter(limit int) *RateLimiter {
-	return &RateLimiter{limit: float64(limit)}
+	return NewLimiter(Limit(limit), 1)
 }
 
-func (r *RateLimiter) CanTake() bool {
+// NewLimiter creates a [RateLimiter] allowing operations at the given rate,
+// with bursts of at most burst operations. The limiter starts full.
+func NewLimiter(limit Limit, burst int, opts ...Option) *RateLimiter {
+	checkLimit(limit)
+	checkBurst(burst)
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &RateLimiter{now: o.now, limit: limit, burst: burst}
+}
+
+// Limit returns the current rate limit.
+func (r *RateLimiter) Limit() Limit {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.limit
+}
 
-	r.advance()
-	if r.tokens == 1 {
-		r.tokens--
-		return true
-	}
-	return false
+// Burst returns the current burst size.
+func (r *RateLimiter) Burst() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.burst
 }
 
-func (r *RateLimiter) Take() {
+// SetLimit changes the rate limit. The tokens accrued so far are kept.
+func (r *RateLimiter) SetLimit(limit Limit) {
+	checkLimit(limit)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(r.now()) // accrue at the old rate
+	r.limit = limit
+}
+
+// SetBurst changes the burst size. The tokens accrued so far are kept, up to the new burst.
+func (r *RateLimiter) SetBurst(burst int) {
+	checkBurst(burst)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.advance(r.now())
+	r.burst = burst
+	r.tokens = min(r.tokens, float64(burst))
+}
+
+// Tokens is shorthand for TokensAt(now) with the current time of the limiter.
+func (r *RateLimiter) Tokens() float64 {
+	return r.TokensAt(r.now())
+}
+
+// TokensAt returns the number of tokens available at now.
+// It is negative if there are pending reservations.
+func (r *RateLimiter) TokensAt(now time.Time) float64 {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	tokens := r.tokens
+	if now.After(r.last) {
+		tokens = min(tokens+now.Sub(r.last).Seconds()*float64(r.limit), float64(r.burst))
+	}
+	return tokens
+}
+
+func (r *RateLimiter) CanTake() bool {
+	return r.reserveN(r.now(), 1, 0).ok
+}
 
-	r.advance()
-	time.Sleep(r.durationFromTokens(1 - r.tokens)) // 0 returns immediately
-	r.tokens--
+// Take blocks until the operation is allowed.
+func (r *RateLimiter) Take() {
+	_ = r.Wait(context.Background())
+}
+
+// Wait is shorthand for WaitN(ctx, 1).
+func (r *RateLimiter) Wait(ctx context.Context) error {
+	return r.WaitN(ctx, 1)
 }
 
-func (r *RateLimiter) advance() {
-	now := time.Now()
-	delta := now.Sub(r.last).Seconds() * r.limit
-	r.tokens = min(r.tokens+delta, 1) // burst of 1
+// WaitN blocks until n operations are allowed.
+// The tokens are reserved under the lock, but the wait happens outside of it,
+// so other callers are not blocked by it.
+// It returns an error if the context is canceled, or if its deadline is too near to get the tokens,
+// in which case the tokens are not taken.
+func (r *RateLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if burst := r.Burst(); n > burst {
+		return fmt.Errorf("rate: WaitN(n=%d) exceeds the burst of %d", n, burst)
+	}
+
+	now := r.now()
+	maxWait := time.Duration(math.MaxInt64)
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = deadline.Sub(now)
+	}
+	res := r.reserveN(now, n, maxWait)
+	if !res.ok {
+		return ErrWouldExceedDeadline
+	}
+	wait := res.DelayFrom(now)
+	if wait == 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// Give back the tokens we are not going to use.
+		res.Cancel()
+		return ctx.Err()
+	}
+}
+
+func (r *RateLimiter) advance(now time.Time) {
+	if now.Before(r.last) {
+		return // accrued already
+	}
+	delta := now.Sub(r.last).Seconds() * float64(r.limit)
+	r.tokens = min(r.tokens+delta, float64(r.burst))
 	r.last = now
 }
 
-func (r *RateLimiter) durationFromTokens(tokens float64) time.Duration {
-	return time.Duration((tokens / r.limit) * float64(time.Second))
+// durationFromTokens returns how long it takes to accrue the tokens at the limit.
+func (limit Limit) durationFromTokens(tokens float64) time.Duration {
+	return time.Duration((tokens / float64(limit)) * float64(time.Second))
+}
+
+func checkLimit(limit Limit) {
+	if !(limit > 0) {
+		panic("rate: limit must be positive")
+	}
+}
+
+func checkBurst(burst int) {
+	if burst <= 0 {
+		panic("rate: burst must be positive")
+	}
 }
@@ -1,6 +1,8 @@
 package rate
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 )
@@ -105,3 +107,147 @@ func TestTake(t *testing.T) {
 		})
 	}
 }
+
+func TestWaitCancel(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("first Wait() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait() error = %v, want %v", err, context.Canceled)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want right after the cancellation", elapsed)
+	}
+}
+
+func TestWaitDeadline(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	limiter.Take()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Wait(ctx); !errors.Is(err, ErrWouldExceedDeadline) {
+		t.Errorf("Wait() error = %v, want %v", err, ErrWouldExceedDeadline)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait() returned after %v, want immediately", elapsed)
+	}
+
+	// The tokens were not taken, so a wait with a long enough deadline takes about a second.
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != nil {
+		t.Errorf("Wait() error = %v", err)
+	}
+}
+
+func TestWaitDoesNotBlockCanTake(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	limiter.Take()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		limiter.Wait(ctx)
+	}()
+	time.Sleep(10 * time.Millisecond) // let Wait start sleeping
+
+	start := time.Now()
+	if limiter.CanTake() {
+		t.Errorf("CanTake() = true, want false")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("CanTake() blocked for %v", elapsed)
+	}
+	cancel()
+	<-done
+}
+
+func TestWaitNExceedsBurst(t *testing.T) {
+	limiter := NewRateLimiter(100)
+	if err := limiter.WaitN(context.Background(), 2); err == nil {
+		t.Errorf("WaitN(2) error = nil, want error")
+	}
+}
+
+func TestBurst(t *testing.T) {
+	limiter := NewLimiter(1, 5)
+	for i := range 5 {
+		if !limiter.CanTake() {
+			t.Fatalf("CanTake() #%d = false, want a burst of 5", i+1)
+		}
+	}
+	if limiter.CanTake() {
+		t.Errorf("CanTake() #6 = true, want false")
+	}
+	if err := limiter.WaitN(context.Background(), 6); err == nil {
+		t.Errorf("WaitN(6) error = nil, want error")
+	}
+}
+
+func TestFractionalLimit(t *testing.T) {
+	limiter := NewLimiter(Every(20*time.Millisecond), 1)
+	timer := time.NewTimer(500 * time.Millisecond)
+	var total int
+	for loop := true; loop; {
+		select {
+		case <-timer.C:
+			loop = false
+		default:
+		}
+		if limiter.CanTake() {
+			total++
+		}
+	}
+	// 1 initial token plus 25 accrued.
+	if total < 22 || total > 28 {
+		t.Errorf("failed rate; expected between 22 and 28, got: %d", total)
+	}
+}
+
+func TestSetLimit(t *testing.T) {
+	limiter := NewLimiter(Every(time.Hour), 3)
+	limiter.CanTake()
+
+	// The two remaining tokens are kept.
+	limiter.SetLimit(Every(time.Hour / 2))
+	if got := limiter.Limit(); got != Every(time.Hour/2) {
+		t.Errorf("Limit() = %v, want %v", got, Every(time.Hour/2))
+	}
+	for i := range 2 {
+		if !limiter.CanTake() {
+			t.Fatalf("CanTake() #%d = false after SetLimit", i+1)
+		}
+	}
+	if limiter.CanTake() {
+		t.Errorf("CanTake() = true, want false")
+	}
+
+	limiter.SetLimit(1000)
+	time.Sleep(10 * time.Millisecond)
+	if !limiter.CanTake() {
+		t.Errorf("CanTake() = false after raising the limit")
+	}
+}
+
+func TestSetBurst(t *testing.T) {
+	limiter := NewLimiter(Every(time.Hour), 3)
+	limiter.SetBurst(1)
+	if got := limiter.Burst(); got != 1 {
+		t.Errorf("Burst() = %d, want 1", got)
+	}
+	if !limiter.CanTake() {
+		t.Fatalf("CanTake() = false, want true")
+	}
+	if limiter.CanTake() {
+		t.Errorf("CanTake() = true, want tokens capped by the new burst")
+	}
+}
@@ -0,0 +1,112 @@
+package rate
+
+import (
+	"math"
+	"time"
+)
+
+// Reservation holds tokens reserved by a [RateLimiter] for a future operation.
+type Reservation struct {
+	ok        bool
+	r         *RateLimiter
+	tokens    int
+	timeToAct time.Time
+	limit     Limit // at the time of the reservation
+}
+
+// OK reports whether the limiter can provide the requested tokens.
+// If OK is false, Delay returns [math.MaxInt64] and Cancel does nothing.
+func (res *Reservation) OK() bool { return res.ok }
+
+// Delay is shorthand for DelayFrom(now) with the current time of the limiter.
+func (res *Reservation) Delay() time.Duration {
+	if !res.ok {
+		return math.MaxInt64
+	}
+	return res.DelayFrom(res.r.now())
+}
+
+// DelayFrom returns how long the holder must wait from now before acting on the reservation.
+// Zero means act immediately.
+func (res *Reservation) DelayFrom(now time.Time) time.Duration {
+	if !res.ok {
+		return math.MaxInt64
+	}
+	return max(res.timeToAct.Sub(now), 0)
+}
+
+// Cancel is shorthand for CancelAt(now) with the current time of the limiter.
+func (res *Reservation) Cancel() {
+	if !res.ok {
+		return
+	}
+	res.CancelAt(res.r.now())
+}
+
+// CancelAt tells the limiter that the reservation holder will not act on it at now,
+// giving back as many tokens as possible, considering the reservations made after it.
+// It does nothing once the time to act has passed or if the reservation was already canceled.
+func (res *Reservation) CancelAt(now time.Time) {
+	if !res.ok {
+		return
+	}
+	r := res.r
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if res.tokens == 0 || res.timeToAct.Before(now) {
+		return
+	}
+	// The tokens of later reservations were counted against these ones, so they stay taken.
+	restore := float64(res.tokens) - float64(res.limit)*r.lastEvent.Sub(res.timeToAct).Seconds()
+	res.tokens = 0
+	if restore <= 0 {
+		return
+	}
+	r.advance(now)
+	r.tokens = min(r.tokens+restore, float64(r.burst))
+	if res.timeToAct.Equal(r.lastEvent) {
+		if prev := res.timeToAct.Add(-res.limit.durationFromTokens(restore)); !prev.Before(now) {
+			r.lastEvent = prev
+		}
+	}
+}
+
+// Reserve is shorthand for ReserveN(now, 1) with the current time of the limiter.
+func (r *RateLimiter) Reserve() *Reservation {
+	return r.ReserveN(r.now(), 1)
+}
+
+// ReserveN reserves n tokens at now, returning a [Reservation] that tells how long to wait
+// before acting. The tokens are taken even if the holder needs to wait.
+// Unlike [RateLimiter.WaitN], it does not block; the holder must wait for [Reservation.Delay]
+// or call [Reservation.Cancel]. The reservation is not OK if n exceeds the burst.
+func (r *RateLimiter) ReserveN(now time.Time, n int) *Reservation {
+	return r.reserveN(now, n, math.MaxInt64)
+}
+
+// reserveN reserves n tokens if it takes at most maxWait to get them.
+func (r *RateLimiter) reserveN(now time.Time, n int, maxWait time.Duration) *Reservation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if n > r.burst {
+		return &Reservation{r: r}
+	}
+	r.advance(now)
+	tokens := r.tokens - float64(n)
+	var wait time.Duration
+	if tokens < 0 {
+		wait = r.limit.durationFromTokens(-tokens)
+	}
+	if wait > maxWait {
+		return &Reservation{r: r}
+	}
+
+	r.tokens = tokens
+	res := &Reservation{ok: true, r: r, tokens: n, timeToAct: now.Add(wait), limit: r.limit}
+	if res.timeToAct.After(r.lastEvent) {
+		r.lastEvent = res.timeToAct
+	}
+	return res
+}
@@ -0,0 +1,113 @@
+package rate
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestReserve(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	limiter := NewLimiter(10, 2, WithClock(clock.Now))
+
+	tests := []struct {
+		advance   time.Duration
+		n         int
+		wantOK    bool
+		wantDelay time.Duration
+	}{
+		{0, 1, true, 0},
+		{0, 1, true, 0},
+		{0, 1, true, 100 * time.Millisecond}, // the burst is used up
+		{0, 2, true, 300 * time.Millisecond}, // waits for the previous reservation
+		{0, 3, false, math.MaxInt64},         // exceeds the burst
+		{time.Second, 2, true, 0},            // refilled
+	}
+	for i, tt := range tests {
+		clock.Advance(tt.advance)
+		res := limiter.ReserveN(clock.Now(), tt.n)
+		if res.OK() != tt.wantOK {
+			t.Errorf("#%d: OK() = %t, want %t", i, res.OK(), tt.wantOK)
+		}
+		if d := res.Delay(); d != tt.wantDelay {
+			t.Errorf("#%d: Delay() = %v, want %v", i, d, tt.wantDelay)
+		}
+	}
+}
+
+func TestReservationCancel(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	limiter := NewLimiter(10, 1, WithClock(clock.Now))
+	limiter.Reserve() // takes the only token
+
+	res := limiter.Reserve()
+	if d := res.Delay(); d != 100*time.Millisecond {
+		t.Fatalf("Delay() = %v, want 100ms", d)
+	}
+	res.Cancel()
+	res.Cancel() // no-op
+
+	// The canceled token is given back, so the next reservation waits as long as the canceled one would have.
+	if d := limiter.Reserve().Delay(); d != 100*time.Millisecond {
+		t.Errorf("Delay() after Cancel = %v, want 100ms", d)
+	}
+}
+
+func TestReservationCancelWithLaterReservations(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	limiter := NewLimiter(10, 2, WithClock(clock.Now))
+	limiter.ReserveN(clock.Now(), 2)
+
+	res := limiter.ReserveN(clock.Now(), 2) // acts at 200ms
+	later := limiter.Reserve()              // acts at 300ms and counts on the tokens of res
+	if d := later.Delay(); d != 300*time.Millisecond {
+		t.Fatalf("Delay() = %v, want 300ms", d)
+	}
+
+	// Only 1 of the 2 tokens can be given back: the other one is needed by the later reservation.
+	res.Cancel()
+	if d := limiter.Reserve().Delay(); d != 300*time.Millisecond {
+		t.Errorf("Delay() after Cancel = %v, want 300ms", d)
+	}
+}
+
+func TestReservationCancelAfterSetLimit(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	limiter := NewLimiter(10, 2, WithClock(clock.Now))
+	limiter.ReserveN(clock.Now(), 2)
+	res := limiter.ReserveN(clock.Now(), 2) // acts at 200ms
+	limiter.Reserve()                       // acts at 300ms and counts on the tokens of res
+
+	// The later reservation needs 1 token of res at the rate it was made at, not at the new one.
+	limiter.SetLimit(1)
+	res.Cancel()
+	if got := limiter.Tokens(); got != -2 {
+		t.Errorf("Tokens() after Cancel = %v, want -2", got)
+	}
+}
+
+func TestReservationCancelAfterTimeToAct(t *testing.T) {
+	clock := &fakeClock{now: t0}
+	limiter := NewLimiter(10, 1, WithClock(clock.Now))
+	limiter.Reserve()
+	res := limiter.Reserve()
+
+	clock.Advance(200 * time.Millisecond)
+	res.Cancel() // too late, the tokens were used
+	if !limiter.CanTake() {
+		t.Fatalf("CanTake() = false, want true")
+	}
+	if limiter.CanTake() {
+		t.Errorf("CanTake() = true, want the canceled tokens not given back")
+	}
+}
@@ -0,0 +1,126 @@
+package rate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// SlidingLog allows n operations in any window of the given duration.
+// It logs the time of the last n operations, so it is exact but uses O(n) memory.
+type SlidingLog struct {
+	now    func() time.Time
+	window time.Duration
+
+	mu   sync.Mutex
+	log  []time.Time // ring buffer of the last n operations, zero if there were fewer
+	head int         // the oldest operation
+}
+
+// NewSlidingLog creates a [SlidingLog] allowing n operations per window.
+func NewSlidingLog(n int, window time.Duration, opts ...Option) *SlidingLog {
+	checkWindow(n, window)
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &SlidingLog{now: o.now, window: window, log: make([]time.Time, n)}
+}
+
+func (l *SlidingLog) CanTake() bool {
+	return l.take(l.now()) == 0
+}
+
+// Wait blocks until an operation is allowed.
+func (l *SlidingLog) Wait(ctx context.Context) error {
+	return wait(ctx, l.now, l.take)
+}
+
+func (l *SlidingLog) take(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// The oldest of the last n operations must have left the window.
+	if d := l.log[l.head].Add(l.window).Sub(now); d > 0 {
+		return d
+	}
+	l.log[l.head] = now
+	l.head = (l.head + 1) % len(l.log)
+	return 0
+}
+
+// SlidingWindow approximately allows n operations in any window of the given duration.
+// It counts the operations of the current and previous fixed windows,
+// and assumes those of the previous window were spread evenly over it.
+// It uses O(1) memory.
+type SlidingWindow struct {
+	now    func() time.Time
+	n      int
+	window time.Duration
+
+	mu    sync.Mutex
+	start time.Time // start of the current fixed window
+	prev  int       // operations in the previous fixed window
+	curr  int       // operations in the current fixed window
+}
+
+// NewSlidingWindow creates a [SlidingWindow] allowing n operations per window.
+func NewSlidingWindow(n int, window time.Duration, opts ...Option) *SlidingWindow {
+	checkWindow(n, window)
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &SlidingWindow{now: o.now, n: n, window: window}
+}
+
+func (l *SlidingWindow) CanTake() bool {
+	return l.take(l.now()) == 0
+}
+
+// Wait blocks until an operation is allowed.
+func (l *SlidingWindow) Wait(ctx context.Context) error {
+	return wait(ctx, l.now, l.take)
+}
+
+func (l *SlidingWindow) take(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if start := now.Truncate(l.window); start.After(l.start) {
+		if start.Sub(l.start) == l.window {
+			l.prev = l.curr
+		} else {
+			l.prev = 0
+		}
+		l.curr = 0
+		l.start = start
+	}
+	elapsed := now.Sub(l.start)
+	if elapsed < 0 {
+		elapsed = 0 // the clock went back, count now as the start of the window
+	}
+	// The part of the previous window that still overlaps the sliding one.
+	overlap := float64(l.window-elapsed) / float64(l.window)
+	if float64(l.prev)*overlap+float64(l.curr+1) <= float64(l.n) {
+		l.curr++
+		return 0
+	}
+
+	if l.curr >= l.n {
+		return l.window - elapsed // the current window is full on its own
+	}
+	// Wait until the operations of the previous window left in the sliding one are few enough.
+	free := float64(l.n-l.curr-1) / float64(l.prev)
+	until := time.Duration(math.Ceil((1 - free) * float64(l.window)))
+	return max(until-elapsed, 1)
+}
+
+func checkWindow(n int, window time.Duration) {
+	if n <= 0 {
+		panic("rate: n must be positive")
+	}
+	if window <= 0 {
+		panic("rate: window must be positive")
+	}
+}
@@ -0,0 +1,71 @@
+package tinylfu
+
+import "math/bits"
+
+const (
+	sketchDepth = 4
+	maxCount    = 15 // counters saturate like 4-bit counters
+)
+
+// sketch is a count-min sketch estimating the access frequency of keys.
+// Once the number of recorded accesses reaches the sample size,
+// all counters are halved, so that the estimates reflect recent history.
+type sketch struct {
+	rows       [sketchDepth][]uint8
+	mask       uint64
+	additions  int
+	sampleSize int
+}
+
+func newSketch(capacity int) *sketch {
+	width := 1 << bits.Len(uint(max(capacity, 16)-1)) // next power of two
+	s := &sketch{
+		mask:       uint64(width - 1),
+		sampleSize: 10 * capacity,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) increment(h uint64) {
+	added := false
+	for i := range s.rows {
+		c := &s.rows[i][s.index(h, i)]
+		if *c < maxCount {
+			*c++
+			added = true
+		}
+	}
+	if !added {
+		return
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(h uint64) uint8 {
+	m := uint8(maxCount)
+	for i := range s.rows {
+		m = min(m, s.rows[i][s.index(h, i)])
+	}
+	return m
+}
+
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	s.additions /= 2
+}
+
+// index derives the counter index of row i using double hashing.
+func (s *sketch) index(h uint64, i int) uint64 {
+	h1, h2 := h, h>>32|h<<32
+	return (h1 + uint64(i)*(h2|1)) & s.mask
+}
@@ -0,0 +1,130 @@
+// Package tinylfu implements a W-TinyLFU cache.
+//
+// New entries go to a small window LRU. Entries evicted from the window become candidates
+// for the main segmented LRU, which consists of a probation and a protected segment.
+// A candidate is admitted only if its estimated access frequency, tracked by a count-min sketch,
+// is higher than that of the entry it would replace, which protects the main area from scans.
+//
+// See https://arxiv.org/abs/1512.00727.
+package tinylfu
+
+import (
+	"hash/maphash"
+
+	"github.com/denpeshkov/doodles/cache"
+	"github.com/denpeshkov/doodles/lru"
+)
+
+var _ cache.Cache[string, any] = (*TinyLFU[string, any])(nil)
+
+// TinyLFU is a W-TinyLFU cache.
+type TinyLFU[K comparable, V any] struct {
+	window    *lru.LRU[K, V]
+	probation *lru.LRU[K, V]
+	protected *lru.LRU[K, V]
+	mainCap   int
+
+	sketch *sketch
+	seed   maphash.Seed
+}
+
+// New creates a new [TinyLFU] instance.
+// About 1% of the capacity is used for the window and 80% of the rest for the protected segment.
+func New[K comparable, V any](capacity int) *TinyLFU[K, V] {
+	if capacity < 2 {
+		panic("tinylfu: capacity must be >= 2")
+	}
+	windowCap := max(1, capacity/100)
+	mainCap := capacity - windowCap
+	protectedCap := mainCap * 8 / 10
+
+	c := &TinyLFU[K, V]{
+		window:    lru.New[K, V](windowCap),
+		probation: lru.New[K, V](mainCap), // shares the main capacity with protected
+		mainCap:   mainCap,
+		sketch:    newSketch(capacity),
+		seed:      maphash.MakeSeed(),
+	}
+	c.window.OnEvict(func(k K, v V, r lru.EvictReason) {
+		if r == lru.EvictCapacity {
+			c.admit(k, v)
+		}
+	})
+	// A main area too small to be segmented only has the probation segment.
+	if protectedCap > 0 {
+		c.protected = lru.New[K, V](protectedCap)
+		c.protected.OnEvict(func(k K, v V, r lru.EvictReason) {
+			if r == lru.EvictCapacity {
+				// Demote to probation, which has room for it as the promoted entry just left it.
+				c.probation.Put(k, v)
+			}
+		})
+	}
+	return c
+}
+
+func (c *TinyLFU[K, V]) Get(key K) (V, bool) {
+	c.sketch.increment(c.hash(key))
+	if v, ok := c.window.Get(key); ok {
+		return v, true
+	}
+	if c.protected != nil {
+		if v, ok := c.protected.Get(key); ok {
+			return v, true
+		}
+		if v, ok := c.probation.Peek(key); ok {
+			c.probation.Delete(key)
+			c.protected.Put(key, v)
+			return v, true
+		}
+		return *(new(V)), false
+	}
+	return c.probation.Get(key)
+}
+
+func (c *TinyLFU[K, V]) Put(key K, val V) {
+	c.sketch.increment(c.hash(key))
+	switch {
+	case c.window.Contains(key):
+		c.window.Put(key, val)
+	case c.probation.Contains(key):
+		c.probation.Put(key, val)
+	case c.protected != nil && c.protected.Contains(key):
+		c.protected.Put(key, val)
+	default:
+		c.window.Put(key, val)
+	}
+}
+
+// Len returns the number of entries in the cache.
+func (c *TinyLFU[K, V]) Len() int {
+	n := c.window.Len() + c.probation.Len()
+	if c.protected != nil {
+		n += c.protected.Len()
+	}
+	return n
+}
+
+// admit decides whether the candidate evicted from the window enters the main area.
+func (c *TinyLFU[K, V]) admit(key K, val V) {
+	n := c.probation.Len()
+	if c.protected != nil {
+		n += c.protected.Len()
+	}
+	if n < c.mainCap {
+		c.probation.Put(key, val)
+		return
+	}
+	victim, _, ok := c.probation.Oldest()
+	if !ok {
+		return
+	}
+	if c.sketch.estimate(c.hash(key)) > c.sketch.estimate(c.hash(victim)) {
+		c.probation.Delete(victim)
+		c.probation.Put(key, val)
+	}
+}
+
+func (c *TinyLFU[K, V]) hash(key K) uint64 {
+	return maphash.Comparable(c.seed, key)
+}
@@ -0,0 +1,141 @@
+package tinylfu
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/denpeshkov/doodles/cache"
+	"github.com/denpeshkov/doodles/lfu"
+	"github.com/denpeshkov/doodles/lru"
+)
+
+func TestGetPut(t *testing.T) {
+	c := New[int, int](100)
+	for i := range 100 {
+		c.Put(i, i)
+		c.Get(i)
+	}
+	if c.Len() > 100 {
+		t.Errorf("Len() = %d, want <= 100", c.Len())
+	}
+	c.Put(99, 990)
+	if v, ok := c.Get(99); !ok || v != 990 {
+		t.Errorf("Get(99) = %d, %t, want 990, true", v, ok)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	for _, capacity := range []int{2, 3, 10, 150} {
+		c := New[int, int](capacity)
+		rnd := rand.New(rand.NewSource(1))
+		for range 10 * capacity {
+			k := rnd.Intn(4 * capacity)
+			if _, ok := c.Get(k); !ok {
+				c.Put(k, k)
+			}
+			if c.Len() > capacity {
+				t.Fatalf("capacity %d: Len() = %d", capacity, c.Len())
+			}
+		}
+	}
+}
+
+func TestScanResistance(t *testing.T) {
+	c := New[int, int](100)
+	// Make keys 0-49 hot.
+	for range 20 {
+		for k := range 50 {
+			if _, ok := c.Get(k); !ok {
+				c.Put(k, k)
+			}
+		}
+	}
+	// A long scan of one-hit wonders.
+	for k := 1000; k < 10_000; k++ {
+		if _, ok := c.Get(k); !ok {
+			c.Put(k, k)
+		}
+	}
+	var hits int
+	for k := range 50 {
+		if _, ok := c.Get(k); ok {
+			hits++
+		}
+	}
+	if hits < 45 {
+		t.Errorf("%d of 50 hot keys survived the scan, want at least 45", hits)
+	}
+}
+
+func zipfTrace(n int, keys uint64, seed int64) []int {
+	rnd := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rnd, 1.01, 1, keys-1)
+	trace := make([]int, n)
+	for i := range trace {
+		trace[i] = int(zipf.Uint64())
+	}
+	return trace
+}
+
+// scanTrace interleaves a Zipf trace with long scans over keys that are never accessed again.
+func scanTrace(n int, keys uint64, seed int64) []int {
+	zipf := zipfTrace(n, keys, seed)
+	trace := make([]int, 0, 2*n)
+	next := int(keys) // scan keys do not overlap with Zipf keys
+	for i, k := range zipf {
+		trace = append(trace, k)
+		if i%10_000 == 0 {
+			for range 5_000 {
+				trace = append(trace, next)
+				next++
+			}
+		}
+	}
+	return trace
+}
+
+func hitRatio(c cache.Cache[int, int], trace []int) float64 {
+	var hits int
+	for _, k := range trace {
+		if _, ok := c.Get(k); ok {
+			hits++
+			continue
+		}
+		c.Put(k, k)
+	}
+	return float64(hits) / float64(len(trace))
+}
+
+func TestHitRatio(t *testing.T) {
+	const capacity = 1000
+	traces := []struct {
+		name  string
+		trace []int
+	}{
+		{"zipf", zipfTrace(200_000, 100_000, 1)},
+		{"scan", scanTrace(200_000, 100_000, 1)},
+	}
+	for _, tt := range traces {
+		t.Run(tt.name, func(t *testing.T) {
+			lruRatio := hitRatio(lru.New[int, int](capacity), tt.trace)
+			lfuRatio := hitRatio(lfu.New[int, int](capacity), tt.trace)
+			tinyRatio := hitRatio(New[int, int](capacity), tt.trace)
+			t.Logf("lru: %.4f, lfu: %.4f, tinylfu: %.4f", lruRatio, lfuRatio, tinyRatio)
+
+			if tinyRatio <= lruRatio {
+				t.Errorf("tinylfu hit ratio %.4f is not better than lru %.4f", tinyRatio, lruRatio)
+			}
+		})
+	}
+}
+
+func BenchmarkTinyLFU(b *testing.B) {
+	trace := zipfTrace(1<<16, 100_000, 1)
+	c := New[int, int](1000)
+	for i := range b.N {
+		k := trace[i%len(trace)]
+		if _, ok := c.Get(k); !ok {
+			c.Put(k, k)
+		}
+	}
+}